@@ -1,11 +1,10 @@
 module github.com/gopenapi/gopenapi
 
-go 1.14
+go 1.18
 
 require (
 	github.com/GeertJohan/go.rice v1.0.2
 	github.com/buger/jsonparser v1.1.1
-	github.com/dlclark/regexp2 v1.4.0 // indirect
 	github.com/dop251/goja v0.0.0-20210126164150-f5884268f0c0
 	github.com/dop251/goja_nodejs v0.0.0-20201222133159-1629e8d0b836
 	github.com/ghodss/yaml v1.0.0
@@ -13,6 +12,28 @@ require (
 	github.com/mitchellh/mapstructure v1.4.1
 	github.com/op/go-logging v0.0.0-20160315200505-970db520ece7
 	github.com/spf13/cobra v1.1.1
-	github.com/zbysir/goja-parser v0.0.0-20210110144735-949ea35fd94c
+	golang.org/x/tools v0.1.12
 	gopkg.in/yaml.v2 v2.2.8
 )
+
+require (
+	github.com/daaku/go.zipexe v1.0.0 // indirect
+	github.com/dlclark/regexp2 v1.4.0 // indirect
+	github.com/gin-contrib/sse v0.1.0 // indirect
+	github.com/go-playground/locales v0.13.0 // indirect
+	github.com/go-playground/universal-translator v0.17.0 // indirect
+	github.com/go-playground/validator/v10 v10.2.0 // indirect
+	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
+	github.com/golang/protobuf v1.3.3 // indirect
+	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/json-iterator/go v1.1.9 // indirect
+	github.com/leodido/go-urn v1.2.0 // indirect
+	github.com/mattn/go-isatty v0.0.12 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.1 // indirect
+	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/ugorji/go/codec v1.1.7 // indirect
+	golang.org/x/mod v0.12.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.7.0 // indirect
+)
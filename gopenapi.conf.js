@@ -1,49 +1,62 @@
 // buildin module: go that can parse definition path to schema.
+// other buildin modules: 'fs' (read-only, relative to the module root), 'yaml' (parse, stringify) and 'path'.
 import go from 'go';
 
 export default {
+  // typeMapping maps go types to schemas directly instead of parsing their fields, it overrides the builtin mapping.
+  // e.g. {'github.com/shopspring/decimal.Decimal': {type: 'string', format: 'decimal'}}
+  typeMapping: {},
+  // jsonRequired adds the fields that have no 'omitempty' option in json tag to the 'required' list of the schema,
+  // as they are always present in the json.
+  jsonRequired: false,
+  // readWriteVariants generates 'XRead' and 'XWrite' components for the component 'X' that has '$readOnly' or
+  // '$writeOnly' fields, responses use 'XRead' and request bodies use 'XWrite'.
+  readWriteVariants: false,
+  // envelope wraps responses in a Go type, e.g. {code, msg, data}, the field that has '$payload: true' meta is replaced
+  // by the schema of response.
+  //   type: the envelope used by 'wrap(model.X)' in meta-comments, e.g. './internal/model.Resp'
+  //   auto: wraps every 2xx response by 'type' automatically
+  //   error: the envelope of error responses (4xx, 5xx and default), they are wrapped automatically if it's set
+  envelope: {type: '', auto: false, error: ''},
+  // helpers are functions that can be called in meta-comments (e.g. 'response: errors(400, 404)') and by 'x-$name'
+  // keys (e.g. 'x-$errors: [400, 404]'), the Go types in arguments are parsed to the same shapes as 'go.parse' returns.
+  // the result is used as the value in meta-comments, and is inserted into the document as-is for 'x-$name' keys.
+  helpers: {},
+  // limits stop a buggy config from hanging forever, times are in milliseconds, 0 means no limit.
+  //   callTimeout: the max time of running the config, an 'x-$' key, a helper, hooks or an expression in meta-comments.
+  //   totalTimeout: the max time of all calls in a run.
+  //   maxCallDepth: the max depth of function calls, a RangeError is thrown if it's exceeded.
+  // the config runs in a sandbox: there is no network, the 'fs' module is read-only and limited to the module root, and
+  // 'require' can only read '.js' and '.json' files in the directory of the config, presets and 'node_modules'.
+  limits: {callTimeout: 10000, totalTimeout: 120000, maxCallDepth: 1000},
+  // lifecycle hooks are optional, they can modify the argument in place or return a new value:
+  //   beforeAll(doc): called with the source document before 'x-$' keys are expanded.
+  //   onOperation(operation, {path, method, doc}): called for every operation after the document is generated.
+  //   onSchema(schema, {name, doc}): called for every schema in 'components.schemas' after the document is generated.
+  //   afterAll(doc): called with the complete document at last.
+  // e.g.
+  //   onOperation: (op, {path, method}) => {
+  //     op.operationId = op.operationId || method + path.split('/').map((s) => s.replace(/[{}]/g, '')).join('_')
+  //   },
   filter: function (key, value) {
     switch (key) {
       case 'x-$path': {
-        value = go.parse(value)
-        let responses = parseResponses(value.meta.response)
-        let params = parseParams(value.meta.params)
-        let body = parseBody(value.meta.body)
-
-        let path = {
-          summary: value.summary,
-          description: value.description,
-        }
-
-        if (value.meta.tags) {
-          if (typeof value.meta.tags === 'string') {
-            path.tags = value.meta.tags.split(',').map(i => i.trim())
-          } else {
-            path.tags = value.meta.tags
-          }
-        }
-
-        if (params) {
-          path.parameters = params
-        }
-        if (body) {
-          path.requestBody = body
-        }
-        path.responses = responses
-
-        if (value.meta.security) {
-          path.security = value.meta.security.map((i) => {
-            // for 'security: [token]
-            if (typeof i === 'string') {
-              return {[i]: []}
-            } else {
-              // for 'security: [{token:write}]'
-              return i
+        return parsePath(go.parse(value))
+      }
+      case 'x-$paths': {
+        // generate all paths from functions that have '$route' meta in the package(s), e.g.
+        //   x-$paths: ./internal/delivery/http/handler
+        let pkgs = Array.isArray(value) ? value : [value]
+        let paths = {}
+        pkgs.forEach((pkg) => {
+          (go.routes(pkg) || []).forEach((r) => {
+            if (!paths[r.path]) {
+              paths[r.path] = {}
             }
+            paths[r.path][r.method] = parsePath(go.parse(r.key))
           })
-        }
-
-        return path
+        })
+        return paths
       }
       case 'x-$schema': {
         value = go.parse(value)
@@ -78,18 +91,75 @@ export default {
       }
     }
 
+    // x-$name calls helpers[name], the arguments are the value (or items of the array), e.g.
+    //   x-$paged: ./internal/model.Pet
+    let helper = (go.config.helpers || {})[key.substr(3)]
+    if (helper) {
+      let args = Array.isArray(value) ? value : [value]
+      return helper.apply(null, args.map((a) => typeof a === 'string' ? go.parse(a) : a))
+    }
+
     console.warn('uncased key: ', key)
     return value
   }
 }
 
+// parsePath, parseResponses, parseParams, parseBody and processSchema are exported, so a shared preset (a copy of this
+// file) can be imported by other configs, e.g.
+//   import {parseParams, processSchema} from 'company' // .gopenapi/presets/company/index.js
+// they read options by 'go.config' (the config that is running) instead of 'exports.default', because 'exports' is the
+// preset itself when they are imported.
+
+// 格式化为openApi支持的path(operation)格式, value是go.parse返回的方法定义
+export function parsePath(value) {
+  let responses = parseResponses(value.meta.response)
+  let params = parseParams(value.meta.params)
+  let body = parseBody(value.meta.body)
+
+  let path = {
+    summary: value.summary,
+    description: value.description,
+  }
+
+  if (value.meta.tags) {
+    if (typeof value.meta.tags === 'string') {
+      path.tags = value.meta.tags.split(',').map(i => i.trim())
+    } else {
+      path.tags = value.meta.tags
+    }
+  }
+
+  if (params) {
+    path.parameters = params
+  }
+  if (body) {
+    path.requestBody = body
+  }
+  path.responses = responses
+
+  if (value.meta.security) {
+    path.security = value.meta.security.map((i) => {
+      // for 'security: [token]
+      if (typeof i === 'string') {
+        return {[i]: []}
+      } else {
+        // for 'security: [{token:write}]'
+        return i
+      }
+    })
+  }
+
+  return path
+}
+
 // 格式化为 openApi支持的responses格式, 支持的入参格式:
 // - model.X
 // - {schema: model.X, desc: ''}
 // - schema(any)
 // - #400
 // - {200: xxx(上方三个语法), 400: xxx}
-function parseResponses(r) {
+// code 是状态码, 用于选择自动包装的信封, 默认为200
+export function parseResponses(r, code) {
   if (!r) {
     return {
       "200": {
@@ -110,7 +180,7 @@ function parseResponses(r) {
         description: 'success',
         content: {
           'application/json': {
-            schema: processSchema(r.schema),
+            schema: responseSchema(r.schema, code),
           }
         }
       }
@@ -122,7 +192,7 @@ function parseResponses(r) {
         description: 'success',
         content: {
           'application/json': {
-            schema: processSchema(r),
+            schema: responseSchema(r, code),
           }
         }
       }
@@ -134,7 +204,7 @@ function parseResponses(r) {
         description: r.desc || 'success',
         content: {
           'application/json': {
-            schema: processSchema(r.schema.schema),
+            schema: responseSchema(r.schema.schema, code),
           }
         }
       }
@@ -146,7 +216,7 @@ function parseResponses(r) {
         description: r.desc || 'success',
         content: {
           'application/json': {
-            schema: processSchema(r.schema),
+            schema: responseSchema(r.schema, code),
           }
         }
       }
@@ -163,7 +233,7 @@ function parseResponses(r) {
     // case for {200: xxx, 400: xxx}
     let rsp = {}
     keys.forEach(k => {
-      let ro = parseResponses(r[k]);
+      let ro = parseResponses(r[k], k);
       if (ro) {
         rsp[k] = ro["200"]
       } else {
@@ -177,11 +247,28 @@ function parseResponses(r) {
   }
 }
 
+// responseSchema 处理响应的schema, 并根据状态码使用信封包装(见 envelope 配置), wrap()返回的schema已经包装过了.
+function responseSchema(s, code) {
+  let wrapped = s && s['x-wrapped']
+  s = processSchema(s, {mode: 'read'})
+  if (wrapped) {
+    return s
+  }
+
+  let envelope = go.envelope(code || '200')
+  if (!envelope) {
+    return s
+  }
+  let e = processSchema(envelope.schema, {omitRef: true, mode: 'read'})
+  e.properties[envelope.payload] = s
+  return e
+}
+
 // 格式化为openApi支持的parameters, 支持的入参格式有:
 // - []  - 数组, 则原封不动
 // - model.X  - 将schema转为params
 // - {schema: model.DelPetParams, required: ['id']}
-function parseParams(r) {
+export function parseParams(r) {
   if (!r) {
     return null
   }
@@ -214,7 +301,7 @@ function parseParams(r) {
             } else if (v.tag['form']) {
               name = v.tag['form']
             } else if (v.tag['json']) {
-              name = v.tag['json']
+              name = jsonName(v.tag['json'], k)
             }
 
             if (name === "-") {
@@ -237,6 +324,9 @@ function parseParams(r) {
             required = r.meta['required']
           } else if (v.meta && v.meta['required']) {
             required = v.meta['required']
+          } else if (v.required) {
+            // 'required' rule in binding/validate tag
+            required = true
           }
 
           // console.log('v 2', JSON.stringify(v))
@@ -283,7 +373,7 @@ function parseParams(r) {
               } else if (v.tag['form']) {
                 name = v.tag['form']
               } else if (v.tag['json']) {
-                name = v.tag['json']
+                name = jsonName(v.tag['json'], k)
               }
               if (name === "-") {
                 continue
@@ -312,6 +402,9 @@ function parseParams(r) {
             if (r.required.indexOf(name) !== -1) {
               required = true
             }
+          } else if (v.required) {
+            // 'required' rule in binding/validate tag
+            required = true
           }
 
           let description = v.schema.description;
@@ -341,7 +434,7 @@ function parseParams(r) {
 // - model.X
 // - schema(any)
 // - {schema: model.X, desc: "desc", required: ['id']}
-function parseBody(r) {
+export function parseBody(r) {
   if (!r) {
     return null
   }
@@ -351,7 +444,7 @@ function parseBody(r) {
       description: 'body',
       content: {
         'application/json': {
-          schema: processSchema(r.schema),
+          schema: processSchema(r.schema, {mode: 'write'}),
         }
       }
     }
@@ -363,7 +456,7 @@ function parseBody(r) {
       description: 'body',
       content: {
         'application/json': {
-          schema: processSchema(r),
+          schema: processSchema(r, {mode: 'write'}),
         }
       }
     }
@@ -377,10 +470,10 @@ function parseBody(r) {
     if (r.required && r.required.length) {
       // 对于指定了required值, 则不能使用ref语法
       // note: 实际上也可以使用$ref语法, 但需要结合 allOf关键字使用, 由于swagger文档没有写这种用法, 所以还是不用$ref了.
-      schema = processSchema(r.schema.schema, {omitRef: true});
+      schema = processSchema(r.schema.schema, {omitRef: true, mode: 'write'});
       schema.required = r.required
     } else {
-      schema = processSchema(r.schema.schema);
+      schema = processSchema(r.schema.schema, {mode: 'write'});
     }
 
     if (schema.properties && r.ext) {
@@ -396,7 +489,7 @@ function parseBody(r) {
     }
   } else if (r['schema'] && r['schema']['x-schema']) {
     // for {schema: schema(1), desc: "desc"}
-    let schema = processSchema(r.schema);
+    let schema = processSchema(r.schema, {mode: 'write'});
 
     // add extra properties, e.g.
     //   {ext: {file: {type: string, format: binary}}}
@@ -415,14 +508,67 @@ function parseBody(r) {
   }
 }
 
+// isOpenapi31 returns whether the version of openapi document is 3.1, it supports 'null' type instead of 'nullable'.
+function isOpenapi31() {
+  return (go.openapi || '').indexOf('3.1') === 0
+}
+
+// nullableRef returns a nullable schema that references to ref, as siblings of $ref are ignored.
+function nullableRef(ref) {
+  if (isOpenapi31()) {
+    return {anyOf: [{$ref: ref}, {type: 'null'}]}
+  }
+  return {allOf: [{$ref: ref}], nullable: true}
+}
+
+// readWriteVariants returns whether to use 'XRead' and 'XWrite' components, see 'readWriteVariants' option.
+function readWriteVariants() {
+  return !!go.config.readWriteVariants
+}
+
+// hasReadWrite returns whether the schema has '$readOnly' or '$writeOnly' fields, only these schemas have variants.
+function hasReadWrite(s) {
+  let properties = s.properties || s['x-properties']
+  if (!properties) {
+    return false
+  }
+  return Object.keys(properties).some((k) => {
+    let meta = properties[k].meta
+    return meta && (meta.readOnly || meta.writeOnly)
+  })
+}
+
+// withKeyword sets keyword (e.g. readOnly) to true, as siblings of $ref are ignored in openapi 3.0, allOf is used.
+function withKeyword(s, keyword) {
+  if (s.$ref && !isOpenapi31()) {
+    return {allOf: [s], [keyword]: true}
+  }
+  s[keyword] = true
+  return s
+}
+
+// jsonName returns the name of field in json, e.g. 'id,omitempty' => 'id', ',omitempty' => key
+function jsonName(tag, key) {
+  if (tag === '-') {
+    return '-'
+  }
+  return tag.split(',')[0] || key
+}
+
 // processSchema process go-schema to openapi-schema.
 // 注意s就算是$ref, 也包含了完整的定义, 这是为了方便在js中制定更多逻辑.
-// options: {omitRef: true则忽略$ref定义, 返回全部定义, false则只返回$ref}
-function processSchema(s, options) {
+// options: {omitRef: true则忽略$ref定义, 返回全部定义, false则只返回$ref
+//           mode: 'read'(response) 或 'write'(request body), 开启了readWriteVariants时会使用对应的component}
+export function processSchema(s, options) {
   if (!s) {
     return null
   }
 
+  let mode = options && options.mode
+  // 子级不需要omitRef
+  let sub = {mode: mode}
+  let variant = readWriteVariants() && mode
+
   // 忽略ref意味着删除$ref值, 而是返回全部值.
   if (options && options.omitRef) {
     if (s.$ref) {
@@ -430,17 +576,28 @@ function processSchema(s, options) {
     }
   } else {
     if (s.$ref) {
-      return {$ref: s.$ref}
+      let ref = s.$ref
+      if (variant && hasReadWrite(s)) {
+        ref += mode === 'read' ? 'Read' : 'Write'
+      }
+      return s.nullable ? nullableRef(ref) : {$ref: ref}
     }
   }
 
   if (s.allOf) {
     s.allOf = s.allOf.map((item) => {
-      return processSchema(item)
+      return processSchema(item, sub)
     })
     delete s['x-properties']
   }
 
+  // interface
+  if (s.oneOf) {
+    s.oneOf = s.oneOf.map((item) => {
+      return processSchema(item, sub)
+    })
+  }
+
   if (s.properties) {
     let p = {}
     Object.keys(s.properties).forEach(function (key) {
@@ -449,7 +606,7 @@ function processSchema(s, options) {
 
       if (v.tag) {
         if (v.tag.json) {
-          name = v.tag.json
+          name = jsonName(v.tag.json, key)
           if (name === '-') {
             // omit this property
             return
@@ -464,19 +621,67 @@ function processSchema(s, options) {
         }
       }
 
-      p[name] = processSchema(v.schema)
+      let schema = processSchema(v.schema, sub)
+      if (v.meta && (v.meta.readOnly || v.meta.writeOnly)) {
+        // 只读的字段不会出现在请求中, 只写的字段不会出现在响应中
+        if (variant && (mode === 'write' ? v.meta.readOnly : v.meta.writeOnly)) {
+          if (s.required) {
+            s.required = s.required.filter((i) => i !== name)
+          }
+          return
+        }
+        schema = withKeyword(schema, v.meta.readOnly ? 'readOnly' : 'writeOnly')
+      }
+
+      p[name] = schema
     })
 
     s.properties = p
   }
 
   if (s.items) {
-    s.items = processSchema(s.items)
+    s.items = processSchema(s.items, sub)
+  }
+
+  // map[string]T
+  if (s.additionalProperties) {
+    s.additionalProperties = processSchema(s.additionalProperties, sub)
   }
 
   if (s['x-schema']) {
     delete s['x-schema']
   }
+  if (s['x-wrapped']) {
+    delete s['x-wrapped']
+  }
+
+  // pointer in go
+  if (s.nullable && isOpenapi31() && typeof s.type === 'string') {
+    s.type = [s.type, 'null']
+    delete s.nullable
+  }
+  if (s.nullable && isOpenapi31() && s.oneOf) {
+    s.oneOf.push({type: 'null'})
+    delete s.nullable
+  }
+
+  // exclusiveMinimum and exclusiveMaximum are numbers instead of booleans in 3.1
+  if (isOpenapi31() && typeof s.exclusiveMinimum === 'boolean') {
+    if (s.exclusiveMinimum) {
+      s.exclusiveMinimum = s.minimum
+      delete s.minimum
+    } else {
+      delete s.exclusiveMinimum
+    }
+  }
+  if (isOpenapi31() && typeof s.exclusiveMaximum === 'boolean') {
+    if (s.exclusiveMaximum) {
+      s.exclusiveMaximum = s.maximum
+      delete s.maximum
+    } else {
+      delete s.exclusiveMaximum
+    }
+  }
 
   if (s['x-any']) {
     delete s['x-any']
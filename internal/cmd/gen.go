@@ -1,4 +1,4 @@
 package cmd
 
-const defaultConfig = "// buildin module: go that can parse definition path to schema.\nimport go from 'go';\n\nexport default {\n  filter: function (key, value) {\n    switch (key) {\n      case 'x-$path': {\n        value = go.parse(value)\n        let responses = parseResponses(value.meta.response)\n        let params = parseParams(value.meta.params)\n        let body = parseBody(value.meta.body)\n\n        let path = {\n          summary: value.summary,\n          description: value.description,\n        }\n\n        if (value.meta.tags) {\n          if (typeof value.meta.tags === 'string') {\n            path.tags = value.meta.tags.split(',').map(i => i.trim())\n          } else {\n            path.tags = value.meta.tags\n          }\n        }\n\n        if (params) {\n          path.parameters = params\n        }\n        if (body) {\n          path.requestBody = body\n        }\n        path.responses = responses\n\n        if (value.meta.security) {\n          path.security = value.meta.security.map((i) => {\n            // for 'security: [token]\n            if (typeof i === 'string') {\n              return {[i]: []}\n            } else {\n              // for 'security: [{token:write}]'\n              return i\n            }\n          })\n        }\n\n        return path\n      }\n      case 'x-$schema': {\n        value = go.parse(value)\n        return processSchema(value.schema, {omitRef: true})\n      }\n      case 'x-$tags': {\n        // for x-tagGroups syntax of redoc\n        let tagGroupsMap = {}\n        value.forEach((i) => {\n          if (i.group) {\n            if (tagGroupsMap[i.group]) {\n              tagGroupsMap[i.group].tags.push(i.name)\n            } else {\n              tagGroupsMap[i.group] = {tags: [i.name]}\n            }\n\n            delete (i.group)\n          }\n        })\n\n        let tagGroups = []\n        for (const k in tagGroupsMap) {\n          tagGroups.push({\n            name: k,\n            tags: tagGroupsMap[k].tags\n          })\n        }\n        return {\n          tags: value,\n          'x-tagGroups': tagGroups\n        }\n      }\n    }\n\n    console.warn('uncased key: ', key)\n    return value\n  }\n}\n\n// 格式化为 openApi支持的responses格式, 支持的入参格式:\n// - model.X\n// - {schema: model.X, desc: ''}\n// - schema(any)\n// - #400\n// - {200: xxx(上方三个语法), 400: xxx}\nfunction parseResponses(r) {\n  if (!r) {\n    return {\n      \"200\": {\n        $ref: '#/components/responses/200',\n      }\n    }\n  }\n  // key全部是数字\n  let keys = Object.keys(r);\n  let allIsInt = keys.length !== 0 && keys.findIndex(i => {\n    return isNaN(parseInt(i))\n  }) === -1\n\n  if (r['x-gostruct']) {\n    // case for model.X\n    return {\n      \"200\": {\n        description: 'success',\n        content: {\n          'application/json': {\n            schema: processSchema(r.schema),\n          }\n        }\n      }\n    }\n  } else if (r['x-schema']) {\n    // case for schema(model.X)\n    return {\n      \"200\": {\n        description: 'success',\n        content: {\n          'application/json': {\n            schema: processSchema(r),\n          }\n        }\n      }\n    }\n  } else if (r.schema && r.schema['x-gostruct']) {\n    // case for {schema: model.X, desc: ''}\n    return {\n      \"200\": {\n        description: r.desc || 'success',\n        content: {\n          'application/json': {\n            schema: processSchema(r.schema.schema),\n          }\n        }\n      }\n    }\n  } else if (r.schema && r.schema['x-schema']) {\n    // case for {schema: schema(model.X), desc: ''}\n    return {\n      \"200\": {\n        description: r.desc || 'success',\n        content: {\n          'application/json': {\n            schema: processSchema(r.schema),\n          }\n        }\n      }\n    }\n  } else if (typeof r === 'string') {\n    if (r[0] === '#' && r[1] !== '/') {\n      // for `#404`\n      return {\"200\": {$ref: '#/components/responses/' + r.substr(1)}}\n    } else {\n      // for `#/components/responses/404`\n      return {\"200\": {$ref: r}}\n    }\n  } else if (allIsInt) {\n    // case for {200: xxx, 400: xxx}\n    let rsp = {}\n    keys.forEach(k => {\n      let ro = parseResponses(r[k]);\n      if (ro) {\n        rsp[k] = ro[\"200\"]\n      } else {\n        console.warn(\"can't parse '\", JSON.stringify(r[k], null, 4), \"' to response\")\n      }\n    })\n\n    return rsp\n  } else {\n    console.warn(\"unexpect type of response: \", JSON.stringify(r))\n  }\n}\n\n// 格式化为openApi支持的parameters, 支持的入参格式有:\n// - []  - 数组, 则原封不动\n// - model.X  - 将schema转为params\n// - {schema: model.DelPetParams, required: ['id']}\nfunction parseParams(r) {\n  if (!r) {\n    return null\n  }\n\n  if (Array.isArray(r)) {\n    // case for []\n    return r\n  }\n\n  if (r[\"x-gostruct\"]) {\n    // case for model.X\n    if (r.schema) {\n      let properties\n      if (r.schema.type === 'object') {\n        properties = r.schema.properties\n      } else if (r.schema.allOf) {\n        // allOf语法\n        properties = r.schema['x-properties']\n      }\n\n      if (properties) {\n        let parmas = []\n        for (let k in properties) {\n          let v = properties[k]\n\n          let name = k\n          if (v.tag) {\n            if (v.tag['uri']) {\n              name = v.tag['uri']\n            } else if (v.tag['form']) {\n              name = v.tag['form']\n            } else if (v.tag['json']) {\n              name = v.tag['json']\n            }\n\n            if (name === \"-\") {\n              continue\n            }\n\n            delete (v['tag'])\n          }\n\n          let xin = 'query'\n\n          if (r.meta && r.meta['in']) {\n            xin = r.meta['in']\n          } else if (v.meta && v.meta['in']) {\n            xin = v.meta['in']\n          }\n\n          let required = null\n          if (r.meta && r.meta['required']) {\n            required = r.meta['required']\n          } else if (v.meta && v.meta['required']) {\n            required = v.meta['required']\n          }\n\n          // console.log('v 2', JSON.stringify(v))\n\n          let description = v.schema.description;\n          delete v.schema.description\n          let item = {\n            name: name,\n            description: description,\n            schema: processSchema(v.schema),\n            in: xin,\n          };\n\n          if (required !== null) {\n            item.required = required\n          }\n\n          parmas.push(item)\n        }\n        return parmas\n      }\n    }\n  } else if (r.schema && r.schema[\"x-gostruct\"]) {\n    // for {schema: model.DelPetParams, required: ['id']}\n    if (r.schema.schema) {\n      let properties\n      if (r.schema.schema.type === 'object') {\n        properties = r.schema.schema.properties\n      } else if (r.schema.schema.allOf) {\n        // allOf语法\n        properties = r.schema.schema['x-properties']\n      }\n\n      if (properties) {\n        let parmas = []\n        for (let k in properties) {\n          let v = properties[k]\n\n          let name = k\n          if (v.tag) {\n            if (v.tag) {\n              if (v.tag['uri']) {\n                name = v.tag['uri']\n              } else if (v.tag['form']) {\n                name = v.tag['form']\n              } else if (v.tag['json']) {\n                name = v.tag['json']\n              }\n              if (name === \"-\") {\n                continue\n              }\n\n              delete (v['tag'])\n            }\n\n            delete (v['tag'])\n          }\n\n          let xin = 'query'\n\n          if (r.meta && r.meta['in']) {\n            xin = r.meta['in']\n          } else if (v.meta && v.meta['in']) {\n            xin = v.meta['in']\n          }\n\n          let required = null\n          if (r.meta && r.meta['required']) {\n            required = r.meta['required']\n          } else if (v.meta && v.meta['required']) {\n            required = v.meta['required']\n          } else if (r.required) {\n            if (r.required.indexOf(name) !== -1) {\n              required = true\n            }\n          }\n\n          let description = v.schema.description;\n          delete v.schema.description\n          let item = {\n            name: name,\n            description: description,\n            schema: processSchema(v.schema),\n            in: xin,\n          };\n\n          if (required !== null) {\n            item.required = required\n          }\n\n          parmas.push(item)\n        }\n        return parmas\n      }\n    }\n  }\n\n  console.warn(\"unexpect type of params: \", JSON.stringify(r, null, 4))\n}\n\n// 格式化为openApi支持的requestBody, 支持的入参格式有:\n// - model.X\n// - schema(any)\n// - {schema: model.X, desc: \"desc\", required: ['id']}\nfunction parseBody(r) {\n  if (!r) {\n    return null\n  }\n  if (r['x-gostruct']) {\n    // for model.Pet\n    return {\n      description: 'body',\n      content: {\n        'application/json': {\n          schema: processSchema(r.schema),\n        }\n      }\n    }\n  }\n  if (r['x-schema']) {\n    // for schema(model.Pet)\n    // 不推荐的写法\n    return {\n      description: 'body',\n      content: {\n        'application/json': {\n          schema: processSchema(r),\n        }\n      }\n    }\n  } else if (r['schema'] && r['schema']['x-gostruct']) {\n    // for {schema: model.Pet, required: ['id']}\n\n    let schema\n\n    // 处理 required\n    // 语法如: {schema: model.Pet, required: ['id']}\n    if (r.required && r.required.length) {\n      // 对于指定了required值, 则不能使用ref语法\n      // note: 实际上也可以使用$ref语法, 但需要结合 allOf关键字使用, 由于swagger文档没有写这种用法, 所以还是不用$ref了.\n      schema = processSchema(r.schema.schema, {omitRef: true});\n      schema.required = r.required\n    } else {\n      schema = processSchema(r.schema.schema);\n    }\n\n    if (schema.properties && r.ext) {\n      schema.properties = Object.assign(schema.properties, r.ext)\n    }\n    return {\n      description: r.desc || 'body',\n      content: {\n        [r.bodySchema || 'application/json']: {\n          schema: schema,\n        }\n      }\n    }\n  } else if (r['schema'] && r['schema']['x-schema']) {\n    // for {schema: schema(1), desc: \"desc\"}\n    let schema = processSchema(r.schema);\n\n    // add extra properties, e.g.\n    //   {ext: {file: {type: string, format: binary}}}\n    if (schema.properties && r.ext) {\n      schema.properties = Object.assign(schema.properties, r.ext)\n    }\n\n    return {\n      description: r.desc || 'body',\n      content: {\n        [r.bodySchema || 'application/json']: {\n          schema: schema,\n        }\n      }\n    }\n  }\n}\n\n// processSchema process go-schema to openapi-schema.\n// 注意s就算是$ref, 也包含了完整的定义, 这是为了方便在js中制定更多逻辑.\n// options: {omitRef: true则忽略$ref定义, 返回全部定义, false则只返回$ref}\nfunction processSchema(s, options) {\n  if (!s) {\n    return null\n  }\n\n  // 忽略ref意味着删除$ref值, 而是返回全部值.\n  if (options && options.omitRef) {\n    if (s.$ref) {\n      delete s.$ref\n    }\n  } else {\n    if (s.$ref) {\n      return {$ref: s.$ref}\n    }\n  }\n\n  if (s.allOf) {\n    s.allOf = s.allOf.map((item) => {\n      return processSchema(item)\n    })\n    delete s['x-properties']\n  }\n\n  if (s.properties) {\n    let p = {}\n    Object.keys(s.properties).forEach(function (key) {\n      let v = s.properties[key]\n      let name = key\n\n      if (v.tag) {\n        if (v.tag.json) {\n          name = v.tag.json\n          if (name === '-') {\n            // omit this property\n            return\n          }\n        }\n        delete (v['tag'])\n      }\n\n      if (v.meta) {\n        if (v.meta.format) {\n          v.schema.format = v.meta.format\n        }\n      }\n\n      p[name] = processSchema(v.schema)\n    })\n\n    s.properties = p\n  }\n\n  if (s.items) {\n    s.items = processSchema(s.items)\n  }\n\n  if (s['x-schema']) {\n    delete s['x-schema']\n  }\n\n  if (s['x-any']) {\n    delete s['x-any']\n    // add 'example' property to fix bug of editor.swagger.io\n    if (!s.example) {\n      s.example = null\n    }\n  }\n\n  return s\n}\n"
+const defaultConfig = "// buildin module: go that can parse definition path to schema.\n// other buildin modules: 'fs' (read-only, relative to the module root), 'yaml' (parse, stringify) and 'path'.\nimport go from 'go';\n\nexport default {\n  // typeMapping maps go types to schemas directly instead of parsing their fields, it overrides the builtin mapping.\n  // e.g. {'github.com/shopspring/decimal.Decimal': {type: 'string', format: 'decimal'}}\n  typeMapping: {},\n  // jsonRequired adds the fields that have no 'omitempty' option in json tag to the 'required' list of the schema,\n  // as they are always present in the json.\n  jsonRequired: false,\n  // readWriteVariants generates 'XRead' and 'XWrite' components for the component 'X' that has '$readOnly' or\n  // '$writeOnly' fields, responses use 'XRead' and request bodies use 'XWrite'.\n  readWriteVariants: false,\n  // envelope wraps responses in a Go type, e.g. {code, msg, data}, the field that has '$payload: true' meta is replaced\n  // by the schema of response.\n  //   type: the envelope used by 'wrap(model.X)' in meta-comments, e.g. './internal/model.Resp'\n  //   auto: wraps every 2xx response by 'type' automatically\n  //   error: the envelope of error responses (4xx, 5xx and default), they are wrapped automatically if it's set\n  envelope: {type: '', auto: false, error: ''},\n  // helpers are functions that can be called in meta-comments (e.g. 'response: errors(400, 404)') and by 'x-$name'\n  // keys (e.g. 'x-$errors: [400, 404]'), the Go types in arguments are parsed to the same shapes as 'go.parse' returns.\n  // the result is used as the value in meta-comments, and is inserted into the document as-is for 'x-$name' keys.\n  helpers: {},\n  // limits stop a buggy config from hanging forever, times are in milliseconds, 0 means no limit.\n  //   callTimeout: the max time of running the config, an 'x-$' key, a helper, hooks or an expression in meta-comments.\n  //   totalTimeout: the max time of all calls in a run.\n  //   maxCallDepth: the max depth of function calls, a RangeError is thrown if it's exceeded.\n  // the config runs in a sandbox: there is no network, the 'fs' module is read-only and limited to the module root, and\n  // 'require' can only read '.js' and '.json' files in the directory of the config, presets and 'node_modules'.\n  limits: {callTimeout: 10000, totalTimeout: 120000, maxCallDepth: 1000},\n  // lifecycle hooks are optional, they can modify the argument in place or return a new value:\n  //   beforeAll(doc): called with the source document before 'x-$' keys are expanded.\n  //   onOperation(operation, {path, method, doc}): called for every operation after the document is generated.\n  //   onSchema(schema, {name, doc}): called for every schema in 'components.schemas' after the document is generated.\n  //   afterAll(doc): called with the complete document at last.\n  // e.g.\n  //   onOperation: (op, {path, method}) => {\n  //     op.operationId = op.operationId || method + path.split('/').map((s) => s.replace(/[{}]/g, '')).join('_')\n  //   },\n  filter: function (key, value) {\n    switch (key) {\n      case 'x-$path': {\n        return parsePath(go.parse(value))\n      }\n      case 'x-$paths': {\n        // generate all paths from functions that have '$route' meta in the package(s), e.g.\n        //   x-$paths: ./internal/delivery/http/handler\n        let pkgs = Array.isArray(value) ? value : [value]\n        let paths = {}\n        pkgs.forEach((pkg) => {\n          (go.routes(pkg) || []).forEach((r) => {\n            if (!paths[r.path]) {\n              paths[r.path] = {}\n            }\n            paths[r.path][r.method] = parsePath(go.parse(r.key))\n          })\n        })\n        return paths\n      }\n      case 'x-$schema': {\n        value = go.parse(value)\n        return processSchema(value.schema, {omitRef: true})\n      }\n      case 'x-$tags': {\n        // for x-tagGroups syntax of redoc\n        let tagGroupsMap = {}\n        value.forEach((i) => {\n          if (i.group) {\n            if (tagGroupsMap[i.group]) {\n              tagGroupsMap[i.group].tags.push(i.name)\n            } else {\n              tagGroupsMap[i.group] = {tags: [i.name]}\n            }\n\n            delete (i.group)\n          }\n        })\n\n        let tagGroups = []\n        for (const k in tagGroupsMap) {\n          tagGroups.push({\n            name: k,\n            tags: tagGroupsMap[k].tags\n          })\n        }\n        return {\n          tags: value,\n          'x-tagGroups': tagGroups\n        }\n      }\n    }\n\n    // x-$name calls helpers[name], the arguments are the value (or items of the array), e.g.\n    //   x-$paged: ./internal/model.Pet\n    let helper = (go.config.helpers || {})[key.substr(3)]\n    if (helper) {\n      let args = Array.isArray(value) ? value : [value]\n      return helper.apply(null, args.map((a) => typeof a === 'string' ? go.parse(a) : a))\n    }\n\n    console.warn('uncased key: ', key)\n    return value\n  }\n}\n\n// parsePath, parseResponses, parseParams, parseBody and processSchema are exported, so a shared preset (a copy of this\n// file) can be imported by other configs, e.g.\n//   import {parseParams, processSchema} from 'company' // .gopenapi/presets/company/index.js\n// they read options by 'go.config' (the config that is running) instead of 'exports.default', because 'exports' is the\n// preset itself when they are imported.\n\n// 格式化为openApi支持的path(operation)格式, value是go.parse返回的方法定义\nexport function parsePath(value) {\n  let responses = parseResponses(value.meta.response)\n  let params = parseParams(value.meta.params)\n  let body = parseBody(value.meta.body)\n\n  let path = {\n    summary: value.summary,\n    description: value.description,\n  }\n\n  if (value.meta.tags) {\n    if (typeof value.meta.tags === 'string') {\n      path.tags = value.meta.tags.split(',').map(i => i.trim())\n    } else {\n      path.tags = value.meta.tags\n    }\n  }\n\n  if (params) {\n    path.parameters = params\n  }\n  if (body) {\n    path.requestBody = body\n  }\n  path.responses = responses\n\n  if (value.meta.security) {\n    path.security = value.meta.security.map((i) => {\n      // for 'security: [token]\n      if (typeof i === 'string') {\n        return {[i]: []}\n      } else {\n        // for 'security: [{token:write}]'\n        return i\n      }\n    })\n  }\n\n  return path\n}\n\n// 格式化为 openApi支持的responses格式, 支持的入参格式:\n// - model.X\n// - {schema: model.X, desc: ''}\n// - schema(any)\n// - #400\n// - {200: xxx(上方三个语法), 400: xxx}\n// code 是状态码, 用于选择自动包装的信封, 默认为200\nexport function parseResponses(r, code) {\n  if (!r) {\n    return {\n      \"200\": {\n        $ref: '#/components/responses/200',\n      }\n    }\n  }\n  // key全部是数字\n  let keys = Object.keys(r);\n  let allIsInt = keys.length !== 0 && keys.findIndex(i => {\n    return isNaN(parseInt(i))\n  }) === -1\n\n  if (r['x-gostruct']) {\n    // case for model.X\n    return {\n      \"200\": {\n        description: 'success',\n        content: {\n          'application/json': {\n            schema: responseSchema(r.schema, code),\n          }\n        }\n      }\n    }\n  } else if (r['x-schema']) {\n    // case for schema(model.X)\n    return {\n      \"200\": {\n        description: 'success',\n        content: {\n          'application/json': {\n            schema: responseSchema(r, code),\n          }\n        }\n      }\n    }\n  } else if (r.schema && r.schema['x-gostruct']) {\n    // case for {schema: model.X, desc: ''}\n    return {\n      \"200\": {\n        description: r.desc || 'success',\n        content: {\n          'application/json': {\n            schema: responseSchema(r.schema.schema, code),\n          }\n        }\n      }\n    }\n  } else if (r.schema && r.schema['x-schema']) {\n    // case for {schema: schema(model.X), desc: ''}\n    return {\n      \"200\": {\n        description: r.desc || 'success',\n        content: {\n          'application/json': {\n            schema: responseSchema(r.schema, code),\n          }\n        }\n      }\n    }\n  } else if (typeof r === 'string') {\n    if (r[0] === '#' && r[1] !== '/') {\n      // for `#404`\n      return {\"200\": {$ref: '#/components/responses/' + r.substr(1)}}\n    } else {\n      // for `#/components/responses/404`\n      return {\"200\": {$ref: r}}\n    }\n  } else if (allIsInt) {\n    // case for {200: xxx, 400: xxx}\n    let rsp = {}\n    keys.forEach(k => {\n      let ro = parseResponses(r[k], k);\n      if (ro) {\n        rsp[k] = ro[\"200\"]\n      } else {\n        console.warn(\"can't parse '\", JSON.stringify(r[k], null, 4), \"' to response\")\n      }\n    })\n\n    return rsp\n  } else {\n    console.warn(\"unexpect type of response: \", JSON.stringify(r))\n  }\n}\n\n// responseSchema 处理响应的schema, 并根据状态码使用信封包装(见 envelope 配置), wrap()返回的schema已经包装过了.\nfunction responseSchema(s, code) {\n  let wrapped = s && s['x-wrapped']\n  s = processSchema(s, {mode: 'read'})\n  if (wrapped) {\n    return s\n  }\n\n  let envelope = go.envelope(code || '200')\n  if (!envelope) {\n    return s\n  }\n  let e = processSchema(envelope.schema, {omitRef: true, mode: 'read'})\n  e.properties[envelope.payload] = s\n  return e\n}\n\n// 格式化为openApi支持的parameters, 支持的入参格式有:\n// - []  - 数组, 则原封不动\n// - model.X  - 将schema转为params\n// - {schema: model.DelPetParams, required: ['id']}\nexport function parseParams(r) {\n  if (!r) {\n    return null\n  }\n\n  if (Array.isArray(r)) {\n    // case for []\n    return r\n  }\n\n  if (r[\"x-gostruct\"]) {\n    // case for model.X\n    if (r.schema) {\n      let properties\n      if (r.schema.type === 'object') {\n        properties = r.schema.properties\n      } else if (r.schema.allOf) {\n        // allOf语法\n        properties = r.schema['x-properties']\n      }\n\n      if (properties) {\n        let parmas = []\n        for (let k in properties) {\n          let v = properties[k]\n\n          let name = k\n          if (v.tag) {\n            if (v.tag['uri']) {\n              name = v.tag['uri']\n            } else if (v.tag['form']) {\n              name = v.tag['form']\n            } else if (v.tag['json']) {\n              name = jsonName(v.tag['json'], k)\n            }\n\n            if (name === \"-\") {\n              continue\n            }\n\n            delete (v['tag'])\n          }\n\n          let xin = 'query'\n\n          if (r.meta && r.meta['in']) {\n            xin = r.meta['in']\n          } else if (v.meta && v.meta['in']) {\n            xin = v.meta['in']\n          }\n\n          let required = null\n          if (r.meta && r.meta['required']) {\n            required = r.meta['required']\n          } else if (v.meta && v.meta['required']) {\n            required = v.meta['required']\n          } else if (v.required) {\n            // 'required' rule in binding/validate tag\n            required = true\n          }\n\n          // console.log('v 2', JSON.stringify(v))\n\n          let description = v.schema.description;\n          delete v.schema.description\n          let item = {\n            name: name,\n            description: description,\n            schema: processSchema(v.schema),\n            in: xin,\n          };\n\n          if (required !== null) {\n            item.required = required\n          }\n\n          parmas.push(item)\n        }\n        return parmas\n      }\n    }\n  } else if (r.schema && r.schema[\"x-gostruct\"]) {\n    // for {schema: model.DelPetParams, required: ['id']}\n    if (r.schema.schema) {\n      let properties\n      if (r.schema.schema.type === 'object') {\n        properties = r.schema.schema.properties\n      } else if (r.schema.schema.allOf) {\n        // allOf语法\n        properties = r.schema.schema['x-properties']\n      }\n\n      if (properties) {\n        let parmas = []\n        for (let k in properties) {\n          let v = properties[k]\n\n          let name = k\n          if (v.tag) {\n            if (v.tag) {\n              if (v.tag['uri']) {\n                name = v.tag['uri']\n              } else if (v.tag['form']) {\n                name = v.tag['form']\n              } else if (v.tag['json']) {\n                name = jsonName(v.tag['json'], k)\n              }\n              if (name === \"-\") {\n                continue\n              }\n\n              delete (v['tag'])\n            }\n\n            delete (v['tag'])\n          }\n\n          let xin = 'query'\n\n          if (r.meta && r.meta['in']) {\n            xin = r.meta['in']\n          } else if (v.meta && v.meta['in']) {\n            xin = v.meta['in']\n          }\n\n          let required = null\n          if (r.meta && r.meta['required']) {\n            required = r.meta['required']\n          } else if (v.meta && v.meta['required']) {\n            required = v.meta['required']\n          } else if (r.required) {\n            if (r.required.indexOf(name) !== -1) {\n              required = true\n            }\n          } else if (v.required) {\n            // 'required' rule in binding/validate tag\n            required = true\n          }\n\n          let description = v.schema.description;\n          delete v.schema.description\n          let item = {\n            name: name,\n            description: description,\n            schema: processSchema(v.schema),\n            in: xin,\n          };\n\n          if (required !== null) {\n            item.required = required\n          }\n\n          parmas.push(item)\n        }\n        return parmas\n      }\n    }\n  }\n\n  console.warn(\"unexpect type of params: \", JSON.stringify(r, null, 4))\n}\n\n// 格式化为openApi支持的requestBody, 支持的入参格式有:\n// - model.X\n// - schema(any)\n// - {schema: model.X, desc: \"desc\", required: ['id']}\nexport function parseBody(r) {\n  if (!r) {\n    return null\n  }\n  if (r['x-gostruct']) {\n    // for model.Pet\n    return {\n      description: 'body',\n      content: {\n        'application/json': {\n          schema: processSchema(r.schema, {mode: 'write'}),\n        }\n      }\n    }\n  }\n  if (r['x-schema']) {\n    // for schema(model.Pet)\n    // 不推荐的写法\n    return {\n      description: 'body',\n      content: {\n        'application/json': {\n          schema: processSchema(r, {mode: 'write'}),\n        }\n      }\n    }\n  } else if (r['schema'] && r['schema']['x-gostruct']) {\n    // for {schema: model.Pet, required: ['id']}\n\n    let schema\n\n    // 处理 required\n    // 语法如: {schema: model.Pet, required: ['id']}\n    if (r.required && r.required.length) {\n      // 对于指定了required值, 则不能使用ref语法\n      // note: 实际上也可以使用$ref语法, 但需要结合 allOf关键字使用, 由于swagger文档没有写这种用法, 所以还是不用$ref了.\n      schema = processSchema(r.schema.schema, {omitRef: true, mode: 'write'});\n      schema.required = r.required\n    } else {\n      schema = processSchema(r.schema.schema, {mode: 'write'});\n    }\n\n    if (schema.properties && r.ext) {\n      schema.properties = Object.assign(schema.properties, r.ext)\n    }\n    return {\n      description: r.desc || 'body',\n      content: {\n        [r.bodySchema || 'application/json']: {\n          schema: schema,\n        }\n      }\n    }\n  } else if (r['schema'] && r['schema']['x-schema']) {\n    // for {schema: schema(1), desc: \"desc\"}\n    let schema = processSchema(r.schema, {mode: 'write'});\n\n    // add extra properties, e.g.\n    //   {ext: {file: {type: string, format: binary}}}\n    if (schema.properties && r.ext) {\n      schema.properties = Object.assign(schema.properties, r.ext)\n    }\n\n    return {\n      description: r.desc || 'body',\n      content: {\n        [r.bodySchema || 'application/json']: {\n          schema: schema,\n        }\n      }\n    }\n  }\n}\n\n// isOpenapi31 returns whether the version of openapi document is 3.1, it supports 'null' type instead of 'nullable'.\nfunction isOpenapi31() {\n  return (go.openapi || '').indexOf('3.1') === 0\n}\n\n// nullableRef returns a nullable schema that references to ref, as siblings of $ref are ignored.\nfunction nullableRef(ref) {\n  if (isOpenapi31()) {\n    return {anyOf: [{$ref: ref}, {type: 'null'}]}\n  }\n  return {allOf: [{$ref: ref}], nullable: true}\n}\n\n// readWriteVariants returns whether to use 'XRead' and 'XWrite' components, see 'readWriteVariants' option.\nfunction readWriteVariants() {\n  return !!go.config.readWriteVariants\n}\n\n// hasReadWrite returns whether the schema has '$readOnly' or '$writeOnly' fields, only these schemas have variants.\nfunction hasReadWrite(s) {\n  let properties = s.properties || s['x-properties']\n  if (!properties) {\n    return false\n  }\n  return Object.keys(properties).some((k) => {\n    let meta = properties[k].meta\n    return meta && (meta.readOnly || meta.writeOnly)\n  })\n}\n\n// withKeyword sets keyword (e.g. readOnly) to true, as siblings of $ref are ignored in openapi 3.0, allOf is used.\nfunction withKeyword(s, keyword) {\n  if (s.$ref && !isOpenapi31()) {\n    return {allOf: [s], [keyword]: true}\n  }\n  s[keyword] = true\n  return s\n}\n\n// jsonName returns the name of field in json, e.g. 'id,omitempty' => 'id', ',omitempty' => key\nfunction jsonName(tag, key) {\n  if (tag === '-') {\n    return '-'\n  }\n  return tag.split(',')[0] || key\n}\n\n// processSchema process go-schema to openapi-schema.\n// 注意s就算是$ref, 也包含了完整的定义, 这是为了方便在js中制定更多逻辑.\n// options: {omitRef: true则忽略$ref定义, 返回全部定义, false则只返回$ref\n//           mode: 'read'(response) 或 'write'(request body), 开启了readWriteVariants时会使用对应的component}\nexport function processSchema(s, options) {\n  if (!s) {\n    return null\n  }\n\n  let mode = options && options.mode\n  // 子级不需要omitRef\n  let sub = {mode: mode}\n  let variant = readWriteVariants() && mode\n\n  // 忽略ref意味着删除$ref值, 而是返回全部值.\n  if (options && options.omitRef) {\n    if (s.$ref) {\n      delete s.$ref\n    }\n  } else {\n    if (s.$ref) {\n      let ref = s.$ref\n      if (variant && hasReadWrite(s)) {\n        ref += mode === 'read' ? 'Read' : 'Write'\n      }\n      return s.nullable ? nullableRef(ref) : {$ref: ref}\n    }\n  }\n\n  if (s.allOf) {\n    s.allOf = s.allOf.map((item) => {\n      return processSchema(item, sub)\n    })\n    delete s['x-properties']\n  }\n\n  // interface\n  if (s.oneOf) {\n    s.oneOf = s.oneOf.map((item) => {\n      return processSchema(item, sub)\n    })\n  }\n\n  if (s.properties) {\n    let p = {}\n    Object.keys(s.properties).forEach(function (key) {\n      let v = s.properties[key]\n      let name = key\n\n      if (v.tag) {\n        if (v.tag.json) {\n          name = jsonName(v.tag.json, key)\n          if (name === '-') {\n            // omit this property\n            return\n          }\n        }\n        delete (v['tag'])\n      }\n\n      if (v.meta) {\n        if (v.meta.format) {\n          v.schema.format = v.meta.format\n        }\n      }\n\n      let schema = processSchema(v.schema, sub)\n      if (v.meta && (v.meta.readOnly || v.meta.writeOnly)) {\n        // 只读的字段不会出现在请求中, 只写的字段不会出现在响应中\n        if (variant && (mode === 'write' ? v.meta.readOnly : v.meta.writeOnly)) {\n          if (s.required) {\n            s.required = s.required.filter((i) => i !== name)\n          }\n          return\n        }\n        schema = withKeyword(schema, v.meta.readOnly ? 'readOnly' : 'writeOnly')\n      }\n\n      p[name] = schema\n    })\n\n    s.properties = p\n  }\n\n  if (s.items) {\n    s.items = processSchema(s.items, sub)\n  }\n\n  // map[string]T\n  if (s.additionalProperties) {\n    s.additionalProperties = processSchema(s.additionalProperties, sub)\n  }\n\n  if (s['x-schema']) {\n    delete s['x-schema']\n  }\n  if (s['x-wrapped']) {\n    delete s['x-wrapped']\n  }\n\n  // pointer in go\n  if (s.nullable && isOpenapi31() && typeof s.type === 'string') {\n    s.type = [s.type, 'null']\n    delete s.nullable\n  }\n  if (s.nullable && isOpenapi31() && s.oneOf) {\n    s.oneOf.push({type: 'null'})\n    delete s.nullable\n  }\n\n  // exclusiveMinimum and exclusiveMaximum are numbers instead of booleans in 3.1\n  if (isOpenapi31() && typeof s.exclusiveMinimum === 'boolean') {\n    if (s.exclusiveMinimum) {\n      s.exclusiveMinimum = s.minimum\n      delete s.minimum\n    } else {\n      delete s.exclusiveMinimum\n    }\n  }\n  if (isOpenapi31() && typeof s.exclusiveMaximum === 'boolean') {\n    if (s.exclusiveMaximum) {\n      s.exclusiveMaximum = s.maximum\n      delete s.maximum\n    } else {\n      delete s.exclusiveMaximum\n    }\n  }\n\n  if (s['x-any']) {\n    delete s['x-any']\n    // add 'example' property to fix bug of editor.swagger.io\n    if (!s.example) {\n      s.example = null\n    }\n  }\n\n  return s\n}\n"
 
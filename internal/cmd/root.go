@@ -12,64 +12,73 @@ import (
 
 const version = "0.0.3"
 
-var rootCmd = &cobra.Command{
-	Use:     "gopenapi",
-	Short:   "gopenapi",
-	Long:    `Gopenapi use javascript to extend and simplify openapi sepc`,
-	Version: version,
-	PreRunE: func(cmd *cobra.Command, args []string) error {
-		confPath := cmd.Flag("config").Value.String()
-		_, err := os.Lstat(confPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				err = ioutil.WriteFile(confPath, []byte(defaultConfig), os.ModePerm)
-				if err != nil {
-					err = fmt.Errorf("wirte default config file err: %w", err)
+// newRootCmd 创建 gopenapi 命令, directives 是用go实现的 x-$ 语法, 会在运行前注册到 OpenApi 中, 见 openapi.Directive
+func newRootCmd(directives []openapi.Directive) *cobra.Command {
+	return &cobra.Command{
+		Use:     "gopenapi",
+		Short:   "gopenapi",
+		Long:    `Gopenapi use javascript to extend and simplify openapi sepc`,
+		Version: version,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			confPath := cmd.Flag("config").Value.String()
+			_, err := os.Lstat(confPath)
+			if err != nil {
+				if os.IsNotExist(err) {
+					err = ioutil.WriteFile(confPath, []byte(defaultConfig), os.ModePerm)
+					if err != nil {
+						err = fmt.Errorf("wirte default config file err: %w", err)
+						return err
+					}
+				} else {
 					return err
 				}
-			} else {
-				return err
 			}
-		}
-		return nil
-	},
-	RunE: func(cmd *cobra.Command, args []string) error {
-		confFile := cmd.Flag("config").Value.String()
-		input := cmd.Flag("input").Value.String()
-		output := cmd.Flag("output").Value.String()
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			confFile := cmd.Flag("config").Value.String()
+			input := cmd.Flag("input").Value.String()
+			output := cmd.Flag("output").Value.String()
 
-		if input == "" || output == "" {
-			return errors.New("invalid input or output, please type 'gopenapi -h' to get help")
-		}
-		o, err := openapi.NewOpenApi("go.mod", confFile)
-		if err != nil {
-			return err
-		}
-		inputBs, err := ioutil.ReadFile(input)
-		if err != nil {
-			return err
-		}
+			if input == "" || output == "" {
+				return errors.New("invalid input or output, please type 'gopenapi -h' to get help")
+			}
+			o, err := openapi.NewOpenApi("go.mod", confFile)
+			if err != nil {
+				return err
+			}
+			err = o.RegisterDirective(directives...)
+			if err != nil {
+				return err
+			}
+			inputBs, err := ioutil.ReadFile(input)
+			if err != nil {
+				return err
+			}
 
-		format := openapi.Yaml
-		if path.Ext(output) == ".json" {
-			format = openapi.Json
-		}
-		outputYaml, err := o.CompleteYaml(string(inputBs), format)
-		if err != nil {
-			return err
-		}
+			format := openapi.Yaml
+			if path.Ext(output) == ".json" {
+				format = openapi.Json
+			}
+			outputYaml, err := o.CompleteYaml(string(inputBs), format)
+			if err != nil {
+				return err
+			}
 
-		err = ioutil.WriteFile(output, []byte(outputYaml), os.ModePerm)
-		if err != nil {
-			return err
-		}
+			err = ioutil.WriteFile(output, []byte(outputYaml), os.ModePerm)
+			if err != nil {
+				return err
+			}
 
-		return nil
-	},
-	SilenceUsage: true,
+			return nil
+		},
+		SilenceUsage: true,
+	}
 }
 
-func Execute() error {
+// Execute 运行 gopenapi 命令, 会注册 directives, 见 newRootCmd
+func Execute(directives ...openapi.Directive) error {
+	rootCmd := newRootCmd(directives)
 	rootCmd.Flags().StringP("config", "c", "gopenapi.conf.js", "Specify the configuration file to be used")
 	rootCmd.Flags().StringP("input", "i", "", "Specify the source file in yaml format")
 	rootCmd.Flags().StringP("output", "o", "", "Specify the output file path")
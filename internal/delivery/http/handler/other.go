@@ -29,3 +29,28 @@ func (h *OtherHandler) Boo(ctx *gin.Context) {
 	var _ model.TestRecursion
 	return
 }
+
+// UpdateAccount test for '$readOnly' and '$writeOnly'
+//
+// $:
+//   body: model.TestAccount
+//   response: model.TestAccount
+func (h *OtherHandler) UpdateAccount(ctx *gin.Context) {
+	return
+}
+
+// GetAccount test for envelope
+//
+// $:
+//   response: {200: model.TestAccount, 201: "wrap(model.TestAccount, model.TestErrResp)", 400: model.TestError}
+func (h *OtherHandler) GetAccount(ctx *gin.Context) {
+	return
+}
+
+// ListPets test for helpers in gopenapi.conf.js
+//
+// $:
+//   response: "js: {200: paged(model.Pet), ...errors(400, 404)}"
+func (h *OtherHandler) ListPets(ctx *gin.Context) {
+	return
+}
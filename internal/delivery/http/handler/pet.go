@@ -13,6 +13,7 @@ type PetHandler struct {
 
 // FindPetByStatus test for return array schema
 //
+// $route: GET /pet/findByStatus
 // $:
 //   params: model.FindPetByStatusParams
 //   response:
@@ -38,6 +39,7 @@ func (h *PetHandler) FindPetByStatus(ctx *gin.Context) {
 // GetPet test for pure js
 //
 // Returns a single pet
+// $route: GET /pet/{id}
 // $:
 //    params: "[{name: 'id', required: true, in: 'path', schema: {type: 'string'}}]"
 //    response:
@@ -66,6 +68,7 @@ func (h *PetHandler) GetPet(ctx *gin.Context) {
 
 // PutPet test for 'requestBody' and add custom attribute: 'required'
 //
+// $route: PUT /pet
 // $:
 //    body: {schema: model.Pet, required: [id]}
 //    response: {desc: "返回新的Pet", schema: model.Pet}
@@ -87,6 +90,7 @@ func (h *PetHandler) PutPet(ctx *gin.Context) {
 
 // DelPet test for 'go-composition' syntax
 //
+// $route: DELETE /pet/{id}
 // $:
 //    params: {schema: model.DelPetParams, required: ['id']}
 func (h *PetHandler) DelPet(ctx *gin.Context) {
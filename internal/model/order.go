@@ -0,0 +1,27 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Order is an order for pets
+type Order struct {
+	Id       int64 `json:"id"`
+	PetId    int64 `json:"petId"`
+	Quantity int32 `json:"quantity"`
+	// ShipDate is type of other package
+	ShipDate time.Time `json:"shipDate"`
+	Complete bool      `json:"complete"`
+	Price    Money     `json:"price"`
+}
+
+// Money is encoded as a decimal string
+// $schema: {type: string, format: decimal}
+type Money struct {
+	Cents int64
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"%d.%02d"`, m.Cents/100, m.Cents%100)), nil
+}
@@ -0,0 +1,34 @@
+package model
+
+// Page is a page of items
+type Page[T any] struct {
+	// Items of current page
+	Items []T `json:"items"`
+	Total int `json:"total"`
+}
+
+// Pair test for generic type with multiple type parameters
+type Pair[K comparable, V any] struct {
+	Key   K `json:"key"`
+	Value V `json:"value"`
+}
+
+// PetPage test for generic type in struct field
+type PetPage struct {
+	Pets   Page[Pet]               `json:"pets"`
+	Tags   *Page[Tag]              `json:"tags"`
+	Counts Pair[string, Page[int]] `json:"counts"`
+}
+
+// Len returns the count of items in current page
+func (p *Page[T]) Len() int {
+	return len(p.Items)
+}
+
+// Get returns the value if key matches
+func (p Pair[K, V]) Get(key K) (v V, ok bool) {
+	if p.Key != key {
+		return
+	}
+	return p.Value, true
+}
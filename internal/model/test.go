@@ -1,6 +1,300 @@
 package model
 
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"strconv"
+	"time"
+)
+
 type TestRecursion struct {
 	Id       int64            `json:"id"`
 	Children []*TestRecursion `json:"children"`
 }
+
+// TestTypes 测试各种复合类型
+type TestTypes struct {
+	Labels map[string]string      `json:"labels"`
+	Pets   map[string]*Pet        `json:"pets"`
+	Extra  map[string]interface{} `json:"extra"`
+	Point  [3]int                 `json:"point"`
+	Paren  (string)               `json:"paren"`
+	// Address 匿名结构体
+	Address struct {
+		City string `json:"city"`
+	} `json:"address"`
+	Callback func()         `json:"callback"`
+	Events   chan string    `json:"events"`
+	Alias    PetStatusAlias `json:"alias"`
+}
+
+type PetStatusAlias = PetStatus
+
+// TestAny 测试预定义的 any
+type TestAny struct {
+	Data  any            `json:"data"`
+	Extra map[string]any `json:"extra"`
+	Page  Page[any]      `json:"page"`
+}
+
+// TestValidate 测试 binding 与 validate tag
+type TestValidate struct {
+	Name   string            `json:"name" binding:"required,min=1,max=100"`
+	Age    int               `json:"age" validate:"gte=0,lt=150"`
+	Email  string            `json:"email" validate:"email"`
+	Color  string            `json:"color" validate:"oneof=red green 'light blue'"`
+	Level  int               `json:"level" binding:"oneof=1 2 3"`
+	Tags   []string          `json:"tags" binding:"required,min=1,dive,uuid"`
+	Labels map[string]string `json:"labels" validate:"max=10"`
+	Code   string            `validate:"len=6"`
+	Mixed  int               `json:"mixed" binding:"oneof=1 x"`
+	Low    TestLevel         `json:"low" binding:"oneof=0 1"`
+	High   TestLevel         `json:"high"`
+	Kind   string            `json:"kind" validate:"eq=pet"`
+	Other  string            `json:"other" validate:"ne=pet"`
+	Score  float64           `json:"score" validate:"gt=0"`
+}
+
+// TestJson 测试 encoding/json 的规则
+type TestJson struct {
+	Id       int64  `json:"id,string"`
+	Name     string `json:"name,omitempty"`
+	Note     string `json:",omitempty" example:"a: b c"`
+	X, Y     int
+	Ignored  string `json:"-"`
+	internal string
+	// 指定了名字的嵌套结构体被当做普通字段
+	Category `json:"category"`
+	// 被忽略的嵌套结构体
+	*Tag `json:"-"`
+}
+
+// TestNumbers 测试基础类型的format与取值范围
+type TestNumbers struct {
+	Int     int        `json:"int"`
+	Int8    int8       `json:"int8"`
+	Uint    uint       `json:"uint"`
+	Uint8   uint8      `json:"uint8"`
+	Float32 float32    `json:"float32"`
+	Float64 float64    `json:"float64"`
+	Bytes   []byte     `json:"bytes"`
+	Complex complex128 `json:"complex"`
+	Name    *string    `json:"name"`
+	Tag     *Tag       `json:"tag"`
+}
+
+// TestLevel 测试iota枚举
+type TestLevel int
+
+const (
+	// TestLevelLow is the lowest level
+	TestLevelLow TestLevel = iota
+	TestLevelMid // middle level
+	TestLevelHigh
+)
+
+// TestFlag 测试常量表达式
+type TestFlag int
+
+const (
+	TestFlagA TestFlag = 1 << iota
+	TestFlagB
+	TestFlagC = TestFlagA | TestFlagB
+)
+
+// TestPrefix 测试字符串拼接
+type TestPrefix string
+
+const testPrefix = "pet_"
+
+const (
+	TestPrefixDog TestPrefix = testPrefix + "dog"
+	TestPrefixCat TestPrefix = testPrefix + "cat"
+)
+
+// TestTimeout 测试依赖了其他包的常量, 无法计算的常量会被忽略
+type TestTimeout int64
+
+const (
+	TestTimeoutNone TestTimeout = 0
+	TestTimeoutSlow TestTimeout = TestTimeout(time.Second)
+)
+
+type TestConsts struct {
+	Level  TestLevel  `json:"level"`
+	Flag   TestFlag   `json:"flag"`
+	Prefix TestPrefix `json:"prefix"`
+}
+
+// TestShape 测试接口的oneOf, 成员是包中实现了接口的类型
+// $discriminator: kind
+type TestShape interface {
+	Area() float64
+}
+
+type TestCircle struct {
+	Kind   string  `json:"kind" binding:"required,oneof=circle"`
+	Radius float64 `json:"radius"`
+}
+
+func (c TestCircle) Area() float64 { return 3.14 * c.Radius * c.Radius }
+
+type TestSquare struct {
+	Kind string  `json:"kind"`
+	Side float64 `json:"side"`
+}
+
+func (s *TestSquare) Area() float64 { return s.Side * s.Side }
+
+// TestRoom 有同名但签名不同的方法, 没有实现 TestShape
+type TestRoom struct {
+	Name string `json:"name"`
+}
+
+func (r TestRoom) Area(unit string) float64 { return 0 }
+
+// TestNamed 测试嵌入的接口
+type TestNamed interface {
+	Name() string
+}
+
+// TestNamedKind 嵌入了同一个包中的接口, 只有 TestLabel 实现了所有方法
+type TestNamedKind interface {
+	TestNamed
+	Kind() string
+}
+
+// TestStringerKind 嵌入了其他包中的接口, 无法找到实现
+type TestStringerKind interface {
+	fmt.Stringer
+	Kind() string
+}
+
+type TestLabel struct {
+	Text string `json:"text"`
+}
+
+func (l TestLabel) Name() string { return l.Text }
+func (l TestLabel) Kind() string { return "label" }
+
+// TestKind 只实现了 TestNamedKind 中的部分方法
+type TestKind string
+
+func (k TestKind) Kind() string { return string(k) }
+
+// TestFigure 测试在注释中指定oneOf
+// $oneOf: [TestCircle, TestSquare]
+// $discriminator:
+//   propertyName: kind
+//   mapping:
+//     c: TestCircle
+//     s: TestSquare
+type TestFigure interface{}
+
+type TestShapes struct {
+	Shape  TestShape   `json:"shape"`
+	Shapes []TestShape `json:"shapes"`
+	Figure TestFigure  `json:"figure"`
+	Any    interface{} `json:"any"`
+}
+
+// TestID 被序列化为36进制的字符串
+type TestID struct {
+	value uint64
+}
+
+func (id TestID) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatUint(id.value, 36)), nil
+}
+
+// TestRaw 自定义了json序列化, 但是没有写$schema
+type TestRaw struct {
+	Value string
+}
+
+func (r *TestRaw) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(r.Value)), nil
+}
+
+type TestMarshaler struct {
+	Id  TestID  `json:"id"`
+	Raw TestRaw `json:"raw"`
+}
+
+// TestNode 测试没有定义为component的递归类型
+type TestNode struct {
+	Value string    `json:"value"`
+	Next  *TestNode `json:"next"`
+}
+
+// TestTreeA 与 TestTreeB 相互递归
+type TestTreeA struct {
+	B *TestTreeB `json:"b"`
+}
+
+type TestTreeB struct {
+	A []TestTreeA `json:"a"`
+}
+
+type TestRecursive struct {
+	Node TestNode  `json:"node"`
+	Tree TestTreeA `json:"tree"`
+}
+
+// TestRepeated 中的类型出现了多次, 但不是递归
+type TestRepeated struct {
+	A     Category   `json:"a"`
+	B     Category   `json:"b"`
+	Items []Category `json:"items"`
+}
+
+// TestAccount 测试只读与只写的字段
+type TestAccount struct {
+	// $readOnly: true
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+	// $writeOnly: true
+	Password string `json:"password"`
+	// $readOnly: true
+	Owner TestOwner `json:"owner"`
+}
+
+// TestOwner 被 TestAccount 引用
+type TestOwner struct {
+	Name string `json:"name"`
+	// $writeOnly: true
+	Token string `json:"token"`
+}
+
+// TestResp 测试响应的信封
+type TestResp struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	// $payload: true
+	Data interface{} `json:"data"`
+}
+
+// TestErrResp 测试错误响应的信封
+type TestErrResp struct {
+	Code int `json:"code"`
+	// $payload: true
+	Error interface{} `json:"error"`
+}
+
+type TestError struct {
+	Reason string `json:"reason"`
+}
+
+// PetAlias 与 Pet 是同一个类型
+type PetAlias = Pet
+
+// YamlItem 是其他module中的类型的别名
+type YamlItem = yaml.MapItem
+
+// TestAliasIdentity 测试别名与原类型使用同一个schema
+type TestAliasIdentity struct {
+	Pet   Pet                `json:"pet"`
+	Alias PetAlias           `json:"alias"`
+	Items Page[YamlItem]     `json:"items"`
+	Raw   Page[yaml.MapItem] `json:"raw"`
+}
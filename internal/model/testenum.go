@@ -0,0 +1,4 @@
+package model
+
+// 在其他文件中声明的枚举值
+const TestLevelMax = TestLevelHigh + 1
@@ -0,0 +1,141 @@
+package goast
+
+import (
+	"fmt"
+	"github.com/gopenapi/gopenapi/internal/pkg/log"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+)
+
+// constValue 是通过go/types计算出的常量
+type constValue struct {
+	value interface{}
+	// typ 是常量的类型, 只有类型定义在当前包时才有值, e.g. const A Level = iota 中的 Level
+	typ ast.Expr
+}
+
+// nopImporter 不解析引入的包, 只计算不依赖其他包的常量, 避免类型检查整个依赖树
+type nopImporter struct{}
+
+func (nopImporter) Import(path string) (*types.Package, error) {
+	return nil, fmt.Errorf("skip import '%s'", path)
+}
+
+// evalConsts 使用 go/types 计算包中所有常量的值, 支持iota, 常量表达式(e.g. 1 << 2, Prefix + "x"), 以及跨文件的常量.
+// 无法计算的常量(如依赖了其他包)不会出现在返回值中.
+func evalConsts(fs *token.FileSet, pkg *ast.Package) map[*ast.Ident]constValue {
+	var files []*ast.File
+	for _, f := range pkg.Files {
+		files = append(files, f)
+	}
+
+	info := &types.Info{
+		Defs: map[*ast.Ident]types.Object{},
+	}
+	conf := types.Config{
+		Importer: nopImporter{},
+		// 忽略类型错误, 尽可能多的计算常量
+		Error: func(err error) {},
+	}
+	tpkg, _ := conf.Check(pkg.Name, fs, files, info)
+
+	consts := map[*ast.Ident]constValue{}
+	for id, obj := range info.Defs {
+		c, ok := obj.(*types.Const)
+		if !ok || c.Val().Kind() == constant.Unknown {
+			continue
+		}
+
+		cv := constValue{value: constantToInterface(c.Val())}
+		if named, ok := c.Type().(*types.Named); ok && named.Obj().Pkg() == tpkg {
+			cv.typ = ast.NewIdent(named.Obj().Name())
+		}
+		consts[id] = cv
+	}
+
+	return consts
+}
+
+// constsOf 返回包中常量的值与类型, 常量名 => constValue.
+// 类型检查整个包比较慢, 所以只在 GetEnum 与 GetConsts 中调用, 结果按包缓存.
+func (p *parseAll) constsOf(path string) (map[string]constValue, error) {
+	if v, ok := p.consts.Load(path); ok {
+		return v.(map[string]constValue), nil
+	}
+
+	fs := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fs, path, buildFileFilter(path), 0)
+	if err != nil {
+		return nil, err
+	}
+	consts := map[string]constValue{}
+	for _, pkg := range pkgs {
+		for id, c := range evalConsts(fs, pkg) {
+			if id.Name != "_" {
+				consts[id.Name] = c
+			}
+		}
+	}
+
+	p.consts.Store(path, consts)
+	return consts, nil
+}
+
+// evalLets 返回由go/types计算了常量的值与类型(包括在组中省略的类型)的let, 不会修改传入的let.
+// 无法计算的常量(如 const Slow Level = Level(time.Second) 依赖了其他包, 或者是复数)会被忽略并打印警告.
+// let 是 parse 返回的包中所有的let, 所以结果按包缓存, 每个警告只打印一次.
+func (p *parseAll) evalLets(path string, let []*Let) ([]*Let, error) {
+	if v, ok := p.lets.Load(path); ok {
+		return v.([]*Let), nil
+	}
+	consts, err := p.constsOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make([]*Let, 0, len(let))
+	for _, l := range let {
+		if !l.Const {
+			r = append(r, l)
+			continue
+		}
+		c, ok := consts[l.Name]
+		if !ok || c.value == nil {
+			log.Warningf("error at %s : the value of const '%s' can't be evaluated, it is omitted", l.File, l.Name)
+			continue
+		}
+		el := *l
+		el.Value = c.value
+		if el.Type == nil {
+			el.Type = c.typ
+		}
+		r = append(r, &el)
+	}
+
+	p.lets.Store(path, r)
+	return r, nil
+}
+
+// constantToInterface 将常量转为基础类型
+func constantToInterface(v constant.Value) interface{} {
+	switch v.Kind() {
+	case constant.Bool:
+		return constant.BoolVal(v)
+	case constant.String:
+		return constant.StringVal(v)
+	case constant.Int:
+		if i, exact := constant.Int64Val(v); exact {
+			return i
+		}
+		f, _ := constant.Float64Val(v)
+		return f
+	case constant.Float:
+		f, _ := constant.Float64Val(v)
+		return f
+	}
+	// 复数, 暂不处理
+	return nil
+}
@@ -1,12 +1,16 @@
 package goast
 
 import (
-	"fmt"
 	"github.com/gopenapi/gopenapi/internal/pkg/gosrc"
+	"github.com/gopenapi/gopenapi/internal/pkg/log"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"path"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // GoParse Parse the go src to:
@@ -15,6 +19,8 @@ import (
 type GoParse struct {
 	gosrc    *gosrc.GoSrc
 	parseAll *parseAll
+	// go/types 检查后的包, 见 typesOf
+	typesPkgs sync.Map
 }
 
 func NewGoParse(gosrc *gosrc.GoSrc) *GoParse {
@@ -50,6 +56,7 @@ func (g *GoParse) getPkgInfo(pkgDir string) (pkg *Pkg, err error) {
 //   or: PetHandler.FuncA
 //   不支持查询结构体成员属性.
 func (g *GoParse) GetDef(pkgDir string, key string) (def *Def, exist bool, err error) {
+	pkgPath := pkgDir
 	pkgDir, err = g.gosrc.MustGetAbsPath(pkgDir)
 	if err != nil {
 		return
@@ -77,6 +84,12 @@ func (g *GoParse) GetDef(pkgDir string, key string) (def *Def, exist bool, err e
 	if err != nil {
 		return nil, false, err
 	}
+	if def.IsAlias && len(kk) == 1 {
+		def.Target, err = g.aliasTarget(pkgPath, kk[0])
+		if err != nil {
+			return nil, false, err
+		}
+	}
 
 	// 如果是 model.X.Func 的语法, 则获取Function定义
 	if len(kk) > 1 {
@@ -102,6 +115,8 @@ type Enum struct {
 	Type   string        `json:"type"`
 	Values []interface{} `json:"values"`
 	Keys   []string      `json:"keys"`
+	// Docs 是每个枚举值的注释
+	Docs []string `json:"docs"`
 }
 
 // GetEnum 获取枚举值, 只支持基础类型.
@@ -118,6 +133,10 @@ func (g *GoParse) GetEnum(pkgDir string, typ string) (enum *Enum, err error) {
 	if !exist {
 		return
 	}
+	let, err = g.parseAll.evalLets(pkgDir, let)
+	if err != nil {
+		return nil, err
+	}
 	enum = &Enum{
 		Type:   typ,
 		Values: nil,
@@ -128,6 +147,7 @@ func (g *GoParse) GetEnum(pkgDir string, typ string) (enum *Enum, err error) {
 			if id.Name == typ {
 				enum.Values = append(enum.Values, l.Value)
 				enum.Keys = append(enum.Keys, l.Name)
+				enum.Docs = append(enum.Docs, strings.TrimSpace(l.Doc.Text()))
 			}
 		}
 	}
@@ -158,16 +178,9 @@ func (g *GoParse) GetFuncOfStruct(pkgDir string, typName string) (enum map[strin
 				break
 			}
 			if len(d.FuncRecv.List) != 0 {
-				expr := d.FuncRecv.List[0].Type
-
-				recvName := ""
-				switch expr := expr.(type) {
-				case *ast.Ident:
-					recvName = expr.Name
-				case *ast.StarExpr:
-					recvName = expr.X.(*ast.Ident).Name
-				default:
-					panic(fmt.Sprintf("uncased Type of FuncRecv: %T", expr))
+				recvName, err := getRecvName(d.FuncRecv.List[0].Type)
+				if err != nil {
+					return nil, err
 				}
 				if recvName == typName {
 					enum[d.Name] = d
@@ -179,6 +192,240 @@ func (g *GoParse) GetFuncOfStruct(pkgDir string, typName string) (enum map[strin
 	return
 }
 
+// GetFuncs 获取包中所有的函数与方法, 按照源码顺序(文件, 行)排序.
+// 返回的Def中File与Key都是基于gomod的引入路径.
+func (g *GoParse) GetFuncs(pkgDir string) (funcs []*Def, err error) {
+	pkgDir, err = g.gosrc.MustGetAbsPath(pkgDir)
+	if err != nil {
+		return
+	}
+
+	defs, _, exist, err := g.parseAll.parse(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return
+	}
+
+	for _, d := range defs {
+		if _, ok := d.Type.(*ast.FuncType); !ok {
+			continue
+		}
+
+		fun := *d
+		fun.File, err = g.gosrc.GetPkgPath(d.File)
+		if err != nil {
+			return nil, err
+		}
+		fun.Key, err = g.gosrc.GetPkgPath(d.Key)
+		if err != nil {
+			return nil, err
+		}
+		funcs = append(funcs, &fun)
+	}
+
+	sort.Slice(funcs, func(i, j int) bool {
+		if funcs[i].File != funcs[j].File {
+			return funcs[i].File < funcs[j].File
+		}
+		return funcs[i].Line < funcs[j].Line
+	})
+
+	return
+}
+
+// GetTypes 获取包中所有的类型定义(不包括函数与方法), 按照源码顺序(文件, 行)排序.
+// 返回的Def中File与Key都是基于gomod的引入路径.
+func (g *GoParse) GetTypes(pkgDir string) (types []*Def, err error) {
+	pkgDir, err = g.gosrc.MustGetAbsPath(pkgDir)
+	if err != nil {
+		return
+	}
+
+	defs, _, exist, err := g.parseAll.parse(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return
+	}
+
+	for _, d := range defs {
+		if d.IsFunc {
+			continue
+		}
+
+		typ := *d
+		typ.File, err = g.gosrc.GetPkgPath(d.File)
+		if err != nil {
+			return nil, err
+		}
+		typ.Key, err = g.gosrc.GetPkgPath(d.Key)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, &typ)
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].File != types[j].File {
+			return types[i].File < types[j].File
+		}
+		return types[i].Line < types[j].Line
+	})
+
+	return
+}
+
+// GetConsts 获取包中所有的常量, 按照源码顺序排序.
+func (g *GoParse) GetConsts(pkgDir string) (consts []*Let, err error) {
+	pkgDir, err = g.gosrc.MustGetAbsPath(pkgDir)
+	if err != nil {
+		return
+	}
+
+	_, let, exist, err := g.parseAll.parse(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return
+	}
+	let, err = g.parseAll.evalLets(pkgDir, let)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range let {
+		if l.Const {
+			consts = append(consts, l)
+		}
+	}
+	return
+}
+
+// GetImplementers 返回包中实现了接口 iface 所有方法的类型(不包括接口), 接收者是指针或值都可以.
+// 方法名与签名(参数与返回值的类型)都相同才算实现, 只支持嵌入同一个包中的接口,
+// 嵌入了无法解析的接口(如其他包中的接口)时打印警告并返回空, 不会只用部分的方法匹配.
+// 按定义的顺序排序.
+func (g *GoParse) GetImplementers(pkgDir string, iface *ast.InterfaceType) (types []*Def, err error) {
+	pkgDir, err = g.gosrc.MustGetAbsPath(pkgDir)
+	if err != nil {
+		return
+	}
+
+	defs, _, exist, err := g.parseAll.parse(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return
+	}
+	// 方法名 => 签名
+	methods := map[string]string{}
+	if embedded := interfaceMethods(defs, iface, methods, map[*ast.InterfaceType]bool{}); embedded != "" {
+		log.Warningf("error at %s : interface embeds '%s' which can't be resolved, its implementers are not searched", pkgDir, embedded)
+		return
+	}
+	if len(methods) == 0 {
+		return
+	}
+
+	// 类型名 => 方法名 => 签名
+	methodSet := map[string]map[string]string{}
+	for _, d := range defs {
+		if d.FuncRecv == nil || len(d.FuncRecv.List) == 0 {
+			continue
+		}
+		recv, err := getRecvName(d.FuncRecv.List[0].Type)
+		if err != nil {
+			return nil, err
+		}
+		if methodSet[recv] == nil {
+			methodSet[recv] = map[string]string{}
+		}
+		methodSet[recv][d.Name] = funcSignature(d.Type.(*ast.FuncType))
+	}
+
+	for name, d := range defs {
+		switch d.Type.(type) {
+		case *ast.FuncType, *ast.InterfaceType:
+			continue
+		}
+
+		implemented := true
+		for m, sig := range methods {
+			if s, ok := methodSet[name][m]; !ok || s != sig {
+				implemented = false
+				break
+			}
+		}
+		if !implemented {
+			continue
+		}
+
+		typ := *d
+		typ.File, err = g.gosrc.GetPkgPath(d.File)
+		if err != nil {
+			return nil, err
+		}
+		typ.Key, err = g.gosrc.GetPkgPath(d.Key)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, &typ)
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].File != types[j].File {
+			return types[i].File < types[j].File
+		}
+		return types[i].Line < types[j].Line
+	})
+
+	return
+}
+
+// interfaceMethods 将接口中的方法(包括嵌入的同一个包中的接口的方法)放入 methods, 方法名 => 签名.
+// 返回无法解析的嵌入的接口的源码, 如其他包中的接口(fmt.Stringer)或者类型约束(~int | string).
+func interfaceMethods(defs map[string]*Def, iface *ast.InterfaceType, methods map[string]string, visited map[*ast.InterfaceType]bool) string {
+	if visited[iface] {
+		return ""
+	}
+	visited[iface] = true
+
+	for _, m := range iface.Methods.List {
+		if ft, ok := m.Type.(*ast.FuncType); ok {
+			for _, n := range m.Names {
+				methods[n.Name] = funcSignature(ft)
+			}
+			continue
+		}
+
+		// 嵌入的接口
+		id, ok := m.Type.(*ast.Ident)
+		if !ok {
+			return types.ExprString(m.Type)
+		}
+		if id.Name == "any" {
+			continue
+		}
+		d, ok := defs[id.Name]
+		if !ok {
+			return types.ExprString(m.Type)
+		}
+		embedded, ok := d.Type.(*ast.InterfaceType)
+		if !ok {
+			return types.ExprString(m.Type)
+		}
+		if e := interfaceMethods(defs, embedded, methods, visited); e != "" {
+			return e
+		}
+	}
+	return ""
+}
+
 // FirstValue 返回第一个枚举值, 一般用作default值.
 func (e *Enum) FirstValue() (string, interface{}) {
 	if e == nil {
@@ -204,7 +451,7 @@ type Pkg struct {
 type Pkgs map[string]*Pkg
 
 // GetFileImportedPkgs 获取文件中所有导入的包.
-// Tips: 目前只支持获取文件中导入的**本项目**的其他包.
+// 除了本项目的包, 也支持标准库与依赖的module中的包(通过 go/packages 解析).
 // goFilePath: github.com/gopenapi/gopenapi/internal/delivery/http/handler/pet.go
 func (g *GoParse) GetFileImportedPkgs(filePath string) (pkgs Pkgs, err error) {
 	absPath, err := g.gosrc.MustGetAbsPath(filePath)
@@ -214,12 +461,16 @@ func (g *GoParse) GetFileImportedPkgs(filePath string) (pkgs Pkgs, err error) {
 
 	// todo get from cache if is parsed dir before.
 	fs := token.NewFileSet()
-	f, err := parser.ParseFile(fs, absPath, nil, parser.ParseComments|parser.AllErrors)
+	f, err := parser.ParseFile(fs, absPath, nil, parser.ImportsOnly)
 	if err != nil {
 		return
 	}
 
 	pkgs = make(map[string]*Pkg)
+
+	// 非本项目的包, 统一交给 go/packages 解析
+	var externalPaths []string
+	var externalImports []*ast.ImportSpec
 	for _, imp := range f.Imports {
 		importPath := strings.Trim(imp.Path.Value, `"'`)
 
@@ -227,8 +478,9 @@ func (g *GoParse) GetFileImportedPkgs(filePath string) (pkgs Pkgs, err error) {
 		if err != nil {
 			return nil, err
 		}
-		// 不处理非本项目的包
 		if !isInProject {
+			externalPaths = append(externalPaths, importPath)
+			externalImports = append(externalImports, imp)
 			continue
 		}
 
@@ -251,6 +503,30 @@ func (g *GoParse) GetFileImportedPkgs(filePath string) (pkgs Pkgs, err error) {
 		pkgs[localName] = pkg
 	}
 
+	if len(externalPaths) != 0 {
+		resolved, err := g.gosrc.ResolvePkgs(externalPaths...)
+		if err != nil {
+			return nil, err
+		}
+		for i, importPath := range externalPaths {
+			rp, exist := resolved[importPath]
+			if !exist {
+				// 可能是没有下载的包, 忽略即可
+				continue
+			}
+
+			localName := rp.Name
+			if imp := externalImports[i]; imp.Name != nil {
+				localName = imp.Name.Name
+			}
+
+			pkgs[localName] = &Pkg{
+				Dir:     rp.Path,
+				PkgName: rp.Name,
+			}
+		}
+	}
+
 	return
 }
 
@@ -265,6 +541,37 @@ func (g *GoParse) GetPkgOfFile(filePath string) (pkg string) {
 	return filePath[:x]
 }
 
+// SplitDefPath 将定义的路径分割为包路径与key(类型名, 或者 类型名.方法名), 泛型实例化的实参会保留在key中.
+// 包路径的最后一级(e.g. gopkg.in/yaml.v2)与key(e.g. PetHandler.GetPet)都可能含有".",
+// 所以从最后一个"."开始依次尝试, 使用第一个存在的包, 都不存在时在第一个"."处分割.
+// e.g.
+//   gopkg.in/yaml.v2.MapItem 返回 gopkg.in/yaml.v2, MapItem
+//   ./internal/delivery/http/handler.PetHandler.GetPet 返回 ./internal/delivery/http/handler, PetHandler.GetPet
+func (g *GoParse) SplitDefPath(src string) (pkgPath, key string) {
+	typeArgs := ""
+	if i := strings.IndexByte(src, '['); i != -1 {
+		src, typeArgs = src[:i], src[i:]
+	}
+
+	dir, last := path.Split(src)
+	var dots []int
+	for i, c := range last {
+		if c == '.' {
+			dots = append(dots, i)
+		}
+	}
+	if len(dots) == 0 {
+		return src, ""
+	}
+
+	for i := len(dots) - 1; i > 0; i-- {
+		if p := dir + last[:dots[i]]; g.gosrc.PkgExists(p) {
+			return p, last[dots[i]+1:] + typeArgs
+		}
+	}
+	return dir + last[:dots[0]], last[dots[0]+1:] + typeArgs
+}
+
 func (g *GoParse) FormatPath(path string) (fp string, isInProject bool) {
 	return g.gosrc.FormatPath(path)
 }
@@ -3,6 +3,8 @@ package goast
 import (
 	"encoding/json"
 	"github.com/gopenapi/gopenapi/internal/pkg/gosrc"
+	"go/ast"
+	"reflect"
 	"testing"
 )
 
@@ -57,3 +59,241 @@ func TestGetStructFunc(t *testing.T) {
 	}
 	t.Logf("%s", bs)
 }
+
+func TestGetDefOfExternalPkg(t *testing.T) {
+	goSrc, err := gosrc.NewGoSrcFromModFile("../../../go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewGoParse(goSrc)
+
+	pkgs, err := p.GetFileImportedPkgs("github.com/gopenapi/gopenapi/internal/model/order.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkgs["time"] == nil || pkgs["time"].Dir != "time" {
+		t.Fatalf("want import 'time', got: %+v", pkgs)
+	}
+
+	def, exist, err := p.GetDef("time", "Time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exist {
+		t.Fatal("not exist")
+	}
+	if def.Key != "time.Time" {
+		t.Fatalf("unexpected key: %s", def.Key)
+	}
+	if p.GetPkgOfFile(def.File) != "time" {
+		t.Fatalf("unexpected file: %s", def.File)
+	}
+}
+
+func TestSplitDefPath(t *testing.T) {
+	goSrc, err := gosrc.NewGoSrcFromModFile("../../../go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewGoParse(goSrc)
+
+	cases := map[string][2]string{
+		"gopkg.in/yaml.v2.MapItem":                           {"gopkg.in/yaml.v2", "MapItem"},
+		"./internal/model.Pet":                               {"./internal/model", "Pet"},
+		"./internal/model.Page[./internal/model.Pet]":        {"./internal/model", "Page[./internal/model.Pet]"},
+		"./internal/delivery/http/handler.PetHandler.GetPet": {"./internal/delivery/http/handler", "PetHandler.GetPet"},
+		"time.Time": {"time", "Time"},
+	}
+	for src, want := range cases {
+		pkg, key := p.SplitDefPath(src)
+		if pkg != want[0] || key != want[1] {
+			t.Errorf("%s: want %v, got: %s, %s", src, want, pkg, key)
+		}
+	}
+}
+
+func TestAliasTarget(t *testing.T) {
+	goSrc, err := gosrc.NewGoSrcFromModFile("../../../go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewGoParse(goSrc)
+
+	cases := []struct {
+		pkg, name, want string
+	}{
+		{"./internal/model", "PetAlias", "github.com/gopenapi/gopenapi/internal/model.Pet"},
+		{"./internal/model", "YamlItem", "gopkg.in/yaml.v2.MapItem"},
+		// 跨包的别名链: usecase.PetModel = model.PetAlias = model.Pet
+		{"./internal/usecase", "PetModel", "github.com/gopenapi/gopenapi/internal/model.Pet"},
+		// 不是别名时就是Key
+		{"./internal/model", "Pet", "github.com/gopenapi/gopenapi/internal/model.Pet"},
+	}
+	for _, c := range cases {
+		def, exist, err := p.GetDef(c.pkg, c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !exist {
+			t.Fatalf("%s.%s not exist", c.pkg, c.name)
+		}
+		if k := def.TypeKey(); k != c.want {
+			t.Errorf("%s.%s: want %s, got: %s", c.pkg, c.name, c.want, k)
+		}
+	}
+}
+
+func TestGetEnum(t *testing.T) {
+	goSrc, err := gosrc.NewGoSrcFromModFile("../../../go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewGoParse(goSrc)
+
+	cases := map[string]*Enum{
+		"TestLevel": {
+			Type:   "TestLevel",
+			Values: []interface{}{int64(0), int64(1), int64(2), int64(3)},
+			Keys:   []string{"TestLevelLow", "TestLevelMid", "TestLevelHigh", "TestLevelMax"},
+			Docs:   []string{"TestLevelLow is the lowest level", "middle level", "", ""},
+		},
+		"TestFlag": {
+			Type:   "TestFlag",
+			Values: []interface{}{int64(1), int64(2), int64(3)},
+			Keys:   []string{"TestFlagA", "TestFlagB", "TestFlagC"},
+			Docs:   []string{"", "", ""},
+		},
+		"TestPrefix": {
+			Type:   "TestPrefix",
+			Values: []interface{}{"pet_dog", "pet_cat"},
+			Keys:   []string{"TestPrefixDog", "TestPrefixCat"},
+			Docs:   []string{"", ""},
+		},
+		// TestTimeoutSlow 依赖了time包, 无法计算
+		"TestTimeout": {
+			Type:   "TestTimeout",
+			Values: []interface{}{int64(0)},
+			Keys:   []string{"TestTimeoutNone"},
+			Docs:   []string{""},
+		},
+	}
+	for typ, want := range cases {
+		enum, err := p.GetEnum("github.com/gopenapi/gopenapi/internal/model", typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(enum, want) {
+			t.Errorf("enum of %s, want: %+v, got: %+v", typ, want, enum)
+		}
+	}
+
+	// 常量的值不能是语法树中的节点, 否则无法序列化为json
+	consts, err := p.GetConsts("github.com/gopenapi/gopenapi/internal/model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range consts {
+		if _, err := json.Marshal(c.Value); err != nil {
+			t.Errorf("value of const %s can't be marshaled: %v", c.Name, err)
+		}
+	}
+}
+
+func TestGetImplementers(t *testing.T) {
+	goSrc, err := gosrc.NewGoSrcFromModFile("../../../go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewGoParse(goSrc)
+	pkg := "github.com/gopenapi/gopenapi/internal/model"
+
+	cases := map[string][]string{
+		"TestShape": {"TestCircle", "TestSquare"},
+		// 嵌入的接口的方法也需要实现
+		"TestNamedKind": {"TestLabel"},
+		// 无法解析的嵌入的接口, 不能只用部分方法匹配
+		"TestStringerKind": nil,
+	}
+	for iface, want := range cases {
+		def, exist, err := p.GetDef(pkg, iface)
+		if err != nil || !exist {
+			t.Fatalf("interface %s not found: %v", iface, err)
+		}
+		impls, err := p.GetImplementers(pkg, def.Type.(*ast.InterfaceType))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, d := range impls {
+			names = append(names, d.Name)
+		}
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("implementers of %s, want: %v, got: %v", iface, want, names)
+		}
+	}
+}
+
+func TestGetFuncOfGenericStruct(t *testing.T) {
+	goSrc, err := gosrc.NewGoSrcFromModFile("../../../go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewGoParse(goSrc)
+
+	cases := map[string]string{
+		"Page": "Len",
+		"Pair": "Get",
+	}
+	for typ, want := range cases {
+		funcs, err := p.GetFuncOfStruct("github.com/gopenapi/gopenapi/internal/model", typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(funcs) != 1 || funcs[want] == nil {
+			t.Fatalf("funcs of %s, want: %s, got: %+v", typ, want, funcs)
+		}
+
+		def, exist, err := p.GetDef("github.com/gopenapi/gopenapi/internal/model", typ+"."+want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !exist || def.Key != "github.com/gopenapi/gopenapi/internal/model."+typ+"."+want {
+			t.Fatalf("unexpected def of %s.%s: %+v", typ, want, def)
+		}
+	}
+}
+
+func TestConstsLazy(t *testing.T) {
+	goSrc, err := gosrc.NewGoSrcFromModFile("../../../go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewGoParse(goSrc)
+	pkg := "github.com/gopenapi/gopenapi/internal/model"
+	dir, err := goSrc.MustGetAbsPath(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 查询定义时不需要计算常量
+	if _, _, err := p.GetDef(pkg, "Pet"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.parseAll.consts.Load(dir); ok {
+		t.Fatal("consts should not be evaluated by GetDef")
+	}
+
+	if _, err := p.GetEnum(pkg, "TestLevel"); err != nil {
+		t.Fatal(err)
+	}
+	consts, ok := p.parseAll.consts.Load(dir)
+	if !ok {
+		t.Fatal("consts should be cached after GetEnum")
+	}
+	if _, err := p.GetConsts(pkg); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := p.parseAll.consts.Load(dir); reflect.ValueOf(again).Pointer() != reflect.ValueOf(consts).Pointer() {
+		t.Fatal("consts should be evaluated once per package")
+	}
+}
@@ -3,8 +3,11 @@ package goast
 import (
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/token"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -13,6 +16,10 @@ import (
 // 存储所有类型定义和变量/常量
 type parseAll struct {
 	cache sync.Map
+	// 包中常量的值, 见 constsOf. 包路径 => 常量名 => constValue
+	consts sync.Map
+	// 计算了常量后的let, 见 evalLets. 包路径 => []*Let
+	lets sync.Map
 }
 
 func NewParseAll() *parseAll {
@@ -35,6 +42,24 @@ type Def struct {
 	// 定义在哪个文件(相对路径), e.g. github.com/gopenapi/gopenapi/internal/model/pet.go
 	File string
 	Doc  *ast.CommentGroup
+	// 定义所在的行, 用于按源码顺序排序
+	Line int
+	// IsAlias 表示是类型别名, e.g. type A = B
+	IsAlias bool
+	// Target 是别名指向的类型的标识, 由 go/types 得到, 见 aliasTarget. 指向的不是命名的类型时为空.
+	Target string `json:"-"`
+	// IsFunc 表示是函数或方法的声明, 而不是类型, e.g. type F func() 不是
+	IsFunc bool
+	// TypeParams 是泛型类型的类型参数名, e.g. type Page[T any] struct{} 中的 [T]
+	TypeParams []string
+}
+
+// TypeKey 返回类型的标识. 别名与原类型是同一个类型, 所以返回原类型的标识, 指向的不是命名的类型(如 type IDs = []int)时为空.
+func (d *Def) TypeKey() string {
+	if d.IsAlias {
+		return d.Target
+	}
+	return d.Key
 }
 
 // 变量以及常量
@@ -45,6 +70,8 @@ type Let struct {
 	// 定义在哪个文件
 	File string
 	Doc  *ast.CommentGroup
+	// Const 表示是常量, 否则是变量
+	Const bool
 }
 
 type cacheStruct struct {
@@ -74,7 +101,7 @@ func (p *parseAll) parse(path string) (defs map[string]*Def, let []*Let, exist b
 	}()
 
 	fs := token.NewFileSet()
-	pkgs, err := parser.ParseDir(fs, path, nil, parser.ParseComments|parser.AllErrors)
+	pkgs, err := parser.ParseDir(fs, path, buildFileFilter(path), parser.ParseComments|parser.AllErrors)
 	if err != nil {
 		if strings.Contains(err.Error(), "The system cannot find the file specified.") {
 			return nil, nil, false, nil
@@ -87,7 +114,15 @@ func (p *parseAll) parse(path string) (defs map[string]*Def, let []*Let, exist b
 	defs = map[string]*Def{}
 
 	for _, pkg := range pkgs {
-		for filePath, file := range pkg.Files {
+		// 按文件名排序, 保证跨文件的枚举值顺序是确定的
+		var filePaths []string
+		for filePath := range pkg.Files {
+			filePaths = append(filePaths, filePath)
+		}
+		sort.Strings(filePaths)
+
+		for _, filePath := range filePaths {
+			file := pkg.Files[filePath]
 			for _, decl := range file.Decls {
 				switch decl := decl.(type) {
 				case *ast.GenDecl:
@@ -110,9 +145,24 @@ func (p *parseAll) parse(path string) (defs map[string]*Def, let []*Let, exist b
 								FuncRecv: nil,
 								File:     filePath,
 								Doc:      spec.Doc,
+								Line:     fs.Position(spec.Pos()).Line,
+								IsAlias:  spec.Assign.IsValid(),
+							}
+							if spec.TypeParams != nil {
+								for _, f := range spec.TypeParams.List {
+									for _, n := range f.Names {
+										defs[name].TypeParams = append(defs[name].TypeParams, n.Name)
+									}
+								}
 							}
 						case *ast.ValueSpec:
+							doc := spec.Doc
+							if doc == nil {
+								// 行尾的注释, e.g. A Level = iota // A is ...
+								doc = spec.Comment
+							}
 							for i, name := range spec.Names {
+								// 常量的值与省略的类型需要类型检查, 只在需要时计算, 见 evalLets
 								var value interface{}
 								if len(spec.Values) > i {
 									value = expr2Interface(spec.Values[i])
@@ -121,8 +171,9 @@ func (p *parseAll) parse(path string) (defs map[string]*Def, let []*Let, exist b
 									Value: value,
 									Type:  spec.Type,
 									Name:  name.Name,
-									Doc:   spec.Doc,
+									Doc:   doc,
 									File:  filePath,
+									Const: decl.Tok == token.CONST,
 								})
 							}
 						case *ast.ImportSpec:
@@ -132,15 +183,25 @@ func (p *parseAll) parse(path string) (defs map[string]*Def, let []*Let, exist b
 						}
 					}
 				case *ast.FuncDecl:
-					defs[decl.Name.Name] = &Def{
-						Name: decl.Name.Name,
-						Type: decl.Type,
-						// TODO add decl.Recv name to key
-						// path + "." + {Recv.name} + decl.Name.Name
-						Key:      path + "." + decl.Name.Name,
+					// 方法需要带上接收者的名字, 否则不同结构体上的同名方法会相互覆盖.
+					// e.g. PetHandler.FindPetByStatus
+					name := decl.Name.Name
+					if decl.Recv != nil && len(decl.Recv.List) != 0 {
+						recv, err := getRecvName(decl.Recv.List[0].Type)
+						if err != nil {
+							return nil, nil, false, fmt.Errorf("parse method '%s' in %s err: %w", name, filePath, err)
+						}
+						name = recv + "." + name
+					}
+					defs[name] = &Def{
+						Name:     decl.Name.Name,
+						Type:     decl.Type,
+						Key:      path + "." + name,
 						Doc:      decl.Doc,
 						FuncRecv: decl.Recv,
 						File:     filePath,
+						Line:     fs.Position(decl.Pos()).Line,
+						IsFunc:   true,
 					}
 				default:
 					panic(fmt.Sprintf("uncased decl type %T", decl))
@@ -152,8 +213,23 @@ func (p *parseAll) parse(path string) (defs map[string]*Def, let []*Let, exist b
 	return
 }
 
+// buildFileFilter 只解析参与编译的文件: 忽略测试文件, 以及不满足构建约束(GOOS/GOARCH, build tag)的文件.
+// 对于标准库与依赖的包尤其重要, 否则如 zoneinfo_windows.go 与 zoneinfo_unix.go 中的定义会相互覆盖.
+func buildFileFilter(dir string) func(fi os.FileInfo) bool {
+	return func(fi os.FileInfo) bool {
+		if strings.HasSuffix(fi.Name(), "_test.go") {
+			return false
+		}
+		match, err := build.Default.MatchFile(dir, fi.Name())
+		if err != nil {
+			return false
+		}
+		return match
+	}
+}
+
 // 将表达转为基础的类型
-// 只支持 基础 类型 (ast.BasicLit)
+// 只支持 基础 类型 (ast.BasicLit), 其他的表达式返回nil, 常量的值见 evalLets
 func expr2Interface(expr ast.Expr) interface{} {
 	switch expr := expr.(type) {
 	case *ast.BasicLit:
@@ -174,5 +250,5 @@ func expr2Interface(expr ast.Expr) interface{} {
 		}
 		return expr.Value
 	}
-	return expr
+	return nil
 }
@@ -4,7 +4,7 @@ import "testing"
 
 func TestParseAll(t *testing.T) {
 	pa := NewParseAll()
-	def, let, err := pa.parse("../../model")
+	def, let, _, err := pa.parse("../../model")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,212 @@
+package goast
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// 类型的标识由 go/types 得到: 别名(type A = B)与原类型是同一个类型, 所以别名使用原类型的标识, 即使原类型在其他包中, 见 Def.TypeKey.
+// 其他类型的标识就是 "引入路径.名字", 与 go/types 中 types.TypeName 的 Pkg().Path() + "." + Name() 相同, 所以只有别名需要类型检查.
+
+// typesPkg 是 go/types 检查后的包
+type typesPkg struct {
+	pkg  *types.Package
+	uses map[*ast.Ident]types.Object
+	// 类型名 => 声明
+	specs map[string]*ast.TypeSpec
+}
+
+// typesOf 使用 go/types 检查包中的声明(忽略方法体与类型错误), 结果按包缓存.
+// 引入的包也会被检查(shallow 为true), 但不会再检查它们引入的包, 避免检查整个依赖树.
+// 所以引入的包中依赖了其他包的别名是无效的类型, 见 aliasTarget.
+func (g *GoParse) typesOf(pkgPath string, shallow bool) (*typesPkg, error) {
+	cacheKey := pkgPath
+	if shallow {
+		cacheKey = "shallow:" + pkgPath
+	}
+	if v, ok := g.typesPkgs.Load(cacheKey); ok {
+		return v.(*typesPkg), nil
+	}
+
+	dir, err := g.gosrc.MustGetAbsPath(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	fs := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fs, dir, buildFileFilter(dir), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileNames []string
+	files := map[string]*ast.File{}
+	for _, pkg := range pkgs {
+		for name, f := range pkg.Files {
+			fileNames = append(fileNames, name)
+			files[name] = f
+		}
+	}
+	sort.Strings(fileNames)
+
+	tp := &typesPkg{
+		uses:  map[*ast.Ident]types.Object{},
+		specs: map[string]*ast.TypeSpec{},
+	}
+	var fileList []*ast.File
+	for _, name := range fileNames {
+		f := files[name]
+		fileList = append(fileList, f)
+		for _, decl := range f.Decls {
+			if gd, ok := decl.(*ast.GenDecl); ok {
+				for _, spec := range gd.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						tp.specs[ts.Name.Name] = ts
+					}
+				}
+			}
+		}
+	}
+
+	var importer types.Importer = nopImporter{}
+	if !shallow {
+		importer = shallowImporter{g: g}
+	}
+	conf := types.Config{
+		Importer:         importer,
+		IgnoreFuncBodies: true,
+		// 忽略类型错误, 如引入的包中无法解析的类型
+		Error: func(err error) {},
+	}
+	tp.pkg, _ = conf.Check(pkgPath, fs, fileList, &types.Info{Uses: tp.uses})
+
+	g.typesPkgs.Store(cacheKey, tp)
+	return tp, nil
+}
+
+// shallowImporter 从源码检查引入的包, 见 typesOf
+type shallowImporter struct {
+	g *GoParse
+}
+
+func (i shallowImporter) Import(path string) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	tp, err := i.g.typesOf(path, true)
+	if err != nil {
+		return nil, err
+	}
+	return tp.pkg, nil
+}
+
+// maxAliasDepth 是别名链的最大长度, 防止错误的代码导致死循环
+const maxAliasDepth = 16
+
+// aliasTarget 返回别名指向的类型的标识, e.g. type Item = yaml.MapItem 返回 gopkg.in/yaml.v2.MapItem.
+// 指向的不是命名的类型(如 type IDs = []int), 或者无法解析时返回空.
+func (g *GoParse) aliasTarget(pkgPath, name string) (string, error) {
+	if fp, isInProject := g.gosrc.FormatPath(pkgPath); isInProject {
+		pkgPath = fp
+	}
+
+	for depth := 0; depth < maxAliasDepth; depth++ {
+		tp, err := g.typesOf(pkgPath, false)
+		if err != nil {
+			return "", err
+		}
+		obj, ok := tp.pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return "", nil
+		}
+		if !obj.IsAlias() {
+			return typeIdentity(obj.Type()), nil
+		}
+		if b, ok := unalias(obj.Type()).(*types.Basic); !ok || b.Kind() != types.Invalid {
+			return typeIdentity(obj.Type()), nil
+		}
+
+		// 指向的是引入的包中的别名, 它在引入的包中是无效的类型, 所以在它所在的包中继续查找
+		spec := tp.specs[name]
+		if spec == nil {
+			return "", nil
+		}
+		rhs := spec.Type
+		for p, ok := rhs.(*ast.ParenExpr); ok; p, ok = rhs.(*ast.ParenExpr) {
+			rhs = p.X
+		}
+		var id *ast.Ident
+		switch x := rhs.(type) {
+		case *ast.Ident:
+			id = x
+		case *ast.SelectorExpr:
+			id = x.Sel
+		}
+		target, ok := tp.uses[id].(*types.TypeName)
+		if id == nil || !ok || target.Pkg() == nil {
+			return "", nil
+		}
+		pkgPath, name = target.Pkg().Path(), target.Name()
+	}
+	return "", nil
+}
+
+// typeIdentity 返回类型的标识, 格式与 openapi 中的 typeKey 相同, 没有标识的类型(如匿名结构体)返回空.
+// e.g. github.com/gopenapi/gopenapi/internal/model.Page[github.com/gopenapi/gopenapi/internal/model.Pet]
+func typeIdentity(t types.Type) string {
+	switch t := unalias(t).(type) {
+	case *types.Named:
+		obj := t.Obj()
+		if obj.Pkg() == nil {
+			return ""
+		}
+		key := obj.Pkg().Path() + "." + obj.Name()
+		args := t.TypeArgs()
+		if args.Len() == 0 {
+			return key
+		}
+		keys := make([]string, args.Len())
+		for i := range keys {
+			keys[i] = typeIdentity(args.At(i))
+			if keys[i] == "" {
+				return ""
+			}
+		}
+		return key + "[" + strings.Join(keys, ",") + "]"
+	case *types.Pointer:
+		// 与 typeKey 一样, 指针与原类型的json格式相同
+		return typeIdentity(t.Elem())
+	case *types.Slice:
+		if k := typeIdentity(t.Elem()); k != "" {
+			return "[]" + k
+		}
+	case *types.Array:
+		if k := typeIdentity(t.Elem()); k != "" {
+			return "[]" + k
+		}
+	case *types.Map:
+		kk, vk := typeIdentity(t.Key()), typeIdentity(t.Elem())
+		if kk != "" && vk != "" {
+			return "map[" + kk + "]" + vk
+		}
+	case *types.Basic:
+		if t.Kind() != types.Invalid {
+			return t.Name()
+		}
+	}
+	return ""
+}
+
+// unalias 返回别名指向的类型. 新版本的 go/types 中别名的类型是 *types.Alias, 旧版本中就是原类型.
+func unalias(t types.Type) types.Type {
+	for {
+		a, ok := t.(interface{ Rhs() types.Type })
+		if !ok {
+			return t
+		}
+		t = a.Rhs()
+	}
+}
@@ -1,6 +1,9 @@
 package goast
 
 import (
+	"fmt"
+	"go/ast"
+	"go/types"
 	"path"
 	"strings"
 )
@@ -19,3 +22,45 @@ func splitPkgPath(src string) (pa, member string) {
 	pa = p1
 	return
 }
+
+// getRecvName 返回方法接收者的类型名
+// e.g.
+// - (h *PetHandler) 返回 PetHandler
+// - (h PetHandler) 返回 PetHandler
+// - (p *Page[T]), (p Pair[K, V]) 返回 Page, Pair
+func getRecvName(expr ast.Expr) (string, error) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name, nil
+	case *ast.StarExpr:
+		return getRecvName(expr.X)
+	case *ast.ParenExpr:
+		return getRecvName(expr.X)
+	case *ast.IndexExpr:
+		return getRecvName(expr.X)
+	case *ast.IndexListExpr:
+		return getRecvName(expr.X)
+	default:
+		return "", fmt.Errorf("uncased Type of FuncRecv: %T", expr)
+	}
+}
+
+// funcSignature 返回方法的签名, 不包括参数名, 用于比较两个方法是否相同
+// e.g. func(a, b int, s ...string) (float64, error) 返回 (int, int, ...string) (float64, error)
+func funcSignature(f *ast.FuncType) string {
+	return fieldTypes(f.Params) + " " + fieldTypes(f.Results)
+}
+
+func fieldTypes(fl *ast.FieldList) string {
+	var ts []string
+	if fl != nil {
+		for _, f := range fl.List {
+			t := types.ExprString(f.Type)
+			ts = append(ts, t)
+			for i := 1; i < len(f.Names); i++ {
+				ts = append(ts, t)
+			}
+		}
+	}
+	return "(" + strings.Join(ts, ", ") + ")"
+}
@@ -14,6 +14,9 @@ type GoSrc struct {
 	// module名字
 	ModuleName       string `json:"module_name"`
 	AbsModuleFileDir string
+
+	// 解析非本项目的包
+	resolver *pkgResolver
 }
 
 // 只有当以ModuleName开头的引入路径, 才能被解析.
@@ -39,6 +42,7 @@ func NewGoSrcFromModFile(modFile string) (*GoSrc, error) {
 	return &GoSrc{
 		ModuleName:       string(match[1]),
 		AbsModuleFileDir: absDir,
+		resolver:         newPkgResolver(absDir),
 	}, nil
 }
 
@@ -64,8 +68,76 @@ func (s *GoSrc) GetAbsPath(path string) (absDir string, isInProject bool, err er
 	return
 }
 
+// ResolvePkgs 解析非本项目的包(标准库, 依赖的module, vendor或replace的包), 返回 import path => 包信息.
+// 不存在的包不会出现在返回值中.
+func (s *GoSrc) ResolvePkgs(importPaths ...string) (map[string]*ResolvedPkg, error) {
+	return s.resolver.resolve(importPaths...)
+}
+
+// ResolveAbsPath 和 GetAbsPath 一样获取文件的绝对路径, 不同的是它还支持非本项目的包
+// e.g.
+//   path: time, returned: /usr/local/go/src/time
+//   path: github.com/shopspring/decimal/decimal.go, returned: /root/go/pkg/mod/github.com/shopspring/decimal@v1.2.0/decimal.go
+// return:
+//   exist: 是否能找到此路径
+func (s *GoSrc) ResolveAbsPath(path string) (absDir string, exist bool, err error) {
+	absDir, exist, err = s.GetAbsPath(path)
+	if err != nil || exist {
+		return
+	}
+
+	// 文件路径, 需要先解析包
+	pkgPath, file := path, ""
+	if strings.HasSuffix(path, ".go") {
+		x := strings.LastIndexByte(path, '/')
+		if x == -1 {
+			return
+		}
+		pkgPath, file = path[:x], path[x+1:]
+	}
+
+	pkgs, err := s.resolver.resolve(pkgPath)
+	if err != nil {
+		return
+	}
+	pkg, exist := pkgs[pkgPath]
+	if !exist {
+		return
+	}
+
+	absDir = filepath.Join(pkg.Dir, file)
+	return
+}
+
+// PkgExists 返回包(本项目或依赖中的)是否存在
+func (s *GoSrc) PkgExists(path string) bool {
+	absDir, isInProject, err := s.GetAbsPath(path)
+	if err != nil {
+		return false
+	}
+	if !isInProject {
+		pkgs, err := s.resolver.resolve(path)
+		if err != nil {
+			return false
+		}
+		pkg, exist := pkgs[path]
+		if !exist {
+			return false
+		}
+		absDir = pkg.Dir
+	}
+
+	fi, err := os.Stat(absDir)
+	return err == nil && fi.IsDir()
+}
+
 // GetPkgPath 返回相对路径
+// 对于非本项目的包, 返回的是包的引入路径, e.g. /usr/local/go/src/time/format.go 返回 time/format.go
 func (s *GoSrc) GetPkgPath(absPath string) (pkgPath string, err error) {
+	if p, ok := s.resolver.pkgPathOf(absPath); ok {
+		return p, nil
+	}
+
 	rel, err := filepath.Rel(s.AbsModuleFileDir, absPath)
 	if err != nil {
 		return
@@ -74,13 +146,14 @@ func (s *GoSrc) GetPkgPath(absPath string) (pkgPath string, err error) {
 	return
 }
 
+// MustGetAbsPath 获取本项目或者依赖的包的绝对路径, 如果不存在则返回错误.
 func (s *GoSrc) MustGetAbsPath(path string) (absDir string, err error) {
-	absDir, isInProject, err := s.GetAbsPath(path)
+	absDir, exist, err := s.ResolveAbsPath(path)
 	if err != nil {
 		return
 	}
-	if !isInProject {
-		err = fmt.Errorf("can't resove path: '%s', please ensure it starts with '%s' or './'", path, s.ModuleName)
+	if !exist {
+		err = fmt.Errorf("can't resove path: '%s', please ensure it starts with '%s' or './', or it is a package of dependencies", path, s.ModuleName)
 		return
 	}
 	return
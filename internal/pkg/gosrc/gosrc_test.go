@@ -23,3 +23,45 @@ func TestNewGoSrcFromModFile(t *testing.T) {
 	// Z:\golang\go_project\gopenapi\internal\delivery\http\handler\pet.go
 	t.Logf("%+v %+v", exist, path)
 }
+
+func TestResolveAbsPath(t *testing.T) {
+	gos, err := NewGoSrcFromModFile("../../../go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{
+		// 标准库
+		"time",
+		"time/format.go",
+		// 依赖的module
+		"github.com/gin-gonic/gin",
+		// 本项目
+		"github.com/gopenapi/gopenapi/internal/model",
+	} {
+		abs, exist, err := gos.ResolveAbsPath(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !exist {
+			t.Fatalf("can't resolve '%s'", p)
+		}
+
+		// 绝对路径应该能还原为引入路径
+		pkgPath, err := gos.GetPkgPath(abs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pkgPath != p {
+			t.Fatalf("want: %s, got: %s", p, pkgPath)
+		}
+	}
+
+	_, exist, err := gos.ResolveAbsPath("github.com/not/exist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exist {
+		t.Fatal("want not exist")
+	}
+}
@@ -0,0 +1,154 @@
+package gosrc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// pkgResolver 负责解析非本项目的包, 如标准库, 依赖的其他module中的包.
+// 它通过 go/packages (即 go list) 查询包所在的目录, 所以支持 module cache, vendor 以及 replace.
+// 查询时禁用了GOPROXY, 只会读取本地已有的文件, 不会访问网络.
+// 注意它只用于找到包的目录, 定义仍然由 goast 从源码中解析, 类型由 "引入路径.名字" 区分,
+// 别名指向的类型由 go/types 解析, 见 goast.GoParse.aliasTarget.
+type pkgResolver struct {
+	// 项目根目录, go list 在此目录下执行
+	dir string
+
+	mu sync.Mutex
+	// import path => 包信息, 也会缓存不存在的包
+	pkgs map[string]*ResolvedPkg
+}
+
+// ResolvedPkg 是通过 go/packages 解析到的包信息
+type ResolvedPkg struct {
+	// Path 是规范的引入路径(与go/types中的Package.Path()一致), e.g. github.com/shopspring/decimal
+	Path string
+	// Name 是包名, e.g. decimal
+	Name string
+	// Dir 是包的绝对路径
+	Dir string
+}
+
+func newPkgResolver(dir string) *pkgResolver {
+	return &pkgResolver{
+		dir:  dir,
+		pkgs: map[string]*ResolvedPkg{},
+	}
+}
+
+// resolve 批量解析包, 返回 import path => 包信息, 不存在的包不会出现在返回值中.
+func (r *pkgResolver) resolve(importPaths ...string) (map[string]*ResolvedPkg, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var need []string
+	for _, p := range importPaths {
+		if _, ok := r.pkgs[p]; !ok {
+			need = append(need, p)
+		}
+	}
+
+	if len(need) != 0 {
+		cfg := &packages.Config{
+			Mode: packages.NeedName | packages.NeedFiles,
+			Dir:  r.dir,
+			Env:  r.env(),
+		}
+		pkgs, err := packages.Load(cfg, need...)
+		if err != nil {
+			return nil, fmt.Errorf("load packages %v err: %w", need, err)
+		}
+
+		for _, p := range need {
+			r.pkgs[p] = nil
+		}
+		for _, p := range pkgs {
+			dir := pkgDir(p)
+			if len(p.Errors) != 0 || dir == "" {
+				continue
+			}
+			r.pkgs[p.PkgPath] = &ResolvedPkg{
+				Path: p.PkgPath,
+				Name: p.Name,
+				Dir:  dir,
+			}
+		}
+	}
+
+	rs := map[string]*ResolvedPkg{}
+	for _, p := range importPaths {
+		if pkg := r.pkgs[p]; pkg != nil {
+			rs[p] = pkg
+		}
+	}
+	return rs, nil
+}
+
+// pkgDir 返回包所在的目录, 由包中的文件得到
+func pkgDir(p *packages.Package) string {
+	for _, files := range [][]string{p.GoFiles, p.OtherFiles, p.IgnoredFiles} {
+		if len(files) != 0 {
+			return filepath.Dir(files[0])
+		}
+	}
+	return ""
+}
+
+// env 返回执行 go list 时的环境变量:
+// - 禁用GOPROXY, 保证可以离线运行
+// - 如果项目有vendor目录, 则使用vendor中的包
+func (r *pkgResolver) env() []string {
+	env := append(os.Environ(), "GOPROXY=off")
+	if _, err := os.Stat(filepath.Join(r.dir, "vendor", "modules.txt")); err == nil {
+		env = append(env, "GOFLAGS=-mod=vendor")
+	}
+	return env
+}
+
+// pkgPathOf 将已解析过的包中的绝对路径转为引入路径, 支持包目录, 包中的文件, 以及 "目录.定义" 格式的key.
+// e.g.
+//   /root/go/pkg/mod/github.com/shopspring/decimal@v1.2.0/decimal.go => github.com/shopspring/decimal/decimal.go
+//   /usr/local/go/src/time.Time => time.Time
+func (r *pkgResolver) pkgPathOf(absPath string) (pkgPath string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 使用最长的目录匹配, 防止嵌套的包被错误匹配
+	var dirs []*ResolvedPkg
+	for _, p := range r.pkgs {
+		if p != nil {
+			dirs = append(dirs, p)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return len(dirs[i].Dir) > len(dirs[j].Dir)
+	})
+
+	for _, p := range dirs {
+		if !strings.HasPrefix(absPath, p.Dir) {
+			continue
+		}
+		rest := absPath[len(p.Dir):]
+		if rest == "" {
+			return p.Path, true
+		}
+		switch rest[0] {
+		case os.PathSeparator:
+			if strings.ContainsRune(rest[1:], os.PathSeparator) {
+				// 是子目录中的文件, 不属于此包
+				continue
+			}
+			return p.Path + "/" + rest[1:], true
+		case '.':
+			return p.Path + rest, true
+		}
+	}
+
+	return
+}
@@ -0,0 +1,98 @@
+package js
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// cacheEntry 是缓存文件的内容
+type cacheEntry struct {
+	Code string     `json:"code"`
+	Map  *SourceMap `json:"map"`
+}
+
+// DefaultCacheDir 返回缓存babel结果的目录, 默认为 $XDG_CACHE_HOME/gopenapi/babel (或 ~/.cache/gopenapi/babel).
+// 可以使用环境变量 GOPENAPI_CACHE 指定目录, GOPENAPI_CACHE=off 时不缓存, 返回空字符串.
+func DefaultCacheDir() string {
+	if d := os.Getenv("GOPENAPI_CACHE"); d != "" {
+		if d == "off" {
+			return ""
+		}
+		return d
+	}
+
+	d, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(d, "gopenapi", "babel")
+}
+
+// TransformCached 用于转换配置文件, 与 Transform 相同, 但会生成 source map, 限制调用深度(见 CallDepthFunc), 并将结果缓存在 dir 中, 文件名是源码与选项的sha256.
+// 命中缓存时不会初始化babel(需要约400ms). dir 为空时不缓存; 读写缓存失败时会忽略缓存.
+func TransformCached(src, filename, dir string) (code string, srcmap *SourceMap, err error) {
+	if dir == "" {
+		return transformConfig(src, filename)
+	}
+
+	file := filepath.Join(dir, cacheKey(src, filename)+".json")
+	if bs, err := ioutil.ReadFile(file); err == nil {
+		var e cacheEntry
+		if json.Unmarshal(bs, &e) == nil {
+			return e.Code, e.Map, nil
+		}
+	}
+
+	code, srcmap, err = transformConfig(src, filename)
+	if err != nil {
+		return
+	}
+
+	writeCache(file, cacheEntry{Code: code, Map: srcmap})
+	return
+}
+
+// cacheKey 返回缓存的key. babel的版本, 转换时实际使用的选项(包括自定义插件的源码)与文件名都会影响babel的输出, 所以也需要参与hash.
+func cacheKey(src, filename string) string {
+	// map 的key是排序后序列化的, 所以结果是确定的
+	opts, _ := json.Marshal(mergeOpts(configOpts))
+
+	h := sha256.New()
+	h.Write(babelSourceHash())
+	h.Write([]byte{0})
+	h.Write(opts)
+	h.Write([]byte{0})
+	h.Write([]byte(filepath.Base(filename)))
+	h.Write([]byte{0})
+	h.Write([]byte(src))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeCache 先写临时文件再重命名, 避免同时运行多个gopenapi时读到写了一半的缓存
+func writeCache(file string, e cacheEntry) {
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	dir := filepath.Dir(file)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(bs)
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	if err = os.Rename(f.Name(), file); err != nil {
+		os.Remove(f.Name())
+	}
+}
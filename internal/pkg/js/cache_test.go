@@ -0,0 +1,75 @@
+package js
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestTransformCached(t *testing.T) {
+	dir := t.TempDir()
+	src := "export default {a: (x) => x}"
+
+	code, _, err := TransformCached(src, "gopenapi.conf.js", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("want 1 cache file, got: %v", files)
+	}
+
+	// 修改缓存内容, 第二次应该读取缓存而不是重新转换
+	err = ioutil.WriteFile(files[0], []byte(`{"code": "cached"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, _, err := TransformCached(src, "gopenapi.conf.js", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached != "cached" {
+		t.Fatalf("want cached code, got: %s", cached)
+	}
+
+	// 源码不同时不会命中缓存
+	code2, _, err := TransformCached(src+";", "gopenapi.conf.js", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code2 == "cached" || code == "" {
+		t.Fatalf("unexpected code: %s, %s", code, code2)
+	}
+}
+
+func TestCacheKeyOpts(t *testing.T) {
+	src := "export default {}"
+	key := cacheKey(src, "gopenapi.conf.js")
+
+	old := configOpts
+	defer func() { configOpts = old }()
+
+	// 插件的源码修改后缓存应该失效
+	configOpts = map[string]interface{}{
+		"sourceMaps": true,
+		"plugins":    []interface{}{"transform-object-rest-spread", customPlugin(callDepthPlugin + ";")},
+	}
+	if k := cacheKey(src, "gopenapi.conf.js"); k == key {
+		t.Fatalf("cache key should change with plugin source")
+	}
+
+	// 其他选项修改后缓存也应该失效
+	configOpts = map[string]interface{}{
+		"sourceMaps": false,
+		"plugins":    old["plugins"],
+	}
+	if k := cacheKey(src, "gopenapi.conf.js"); k == key {
+		t.Fatalf("cache key should change with options")
+	}
+
+	configOpts = old
+	if k := cacheKey(src, "gopenapi.conf.js"); k != key {
+		t.Fatalf("cache key should be stable")
+	}
+}
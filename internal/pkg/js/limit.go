@@ -0,0 +1,46 @@
+package js
+
+import (
+	"fmt"
+	"github.com/dop251/goja"
+)
+
+// CallDepthFunc 是配置文件中每个方法开头都会调用的方法, 用于限制调用深度, 见 CheckCallDepth.
+// goja 的调用栈不在go的栈上, 无限递归会一直占用内存直到超时, 所以需要单独限制.
+const CallDepthFunc = "__gopenapi_depth"
+
+// callDepthPlugin 是babel插件, 在每个方法的开头插入 __gopenapi_depth()
+const callDepthPlugin = `(function (babel) {
+  var t = babel.types;
+  return {
+    visitor: {
+      Function: function (path) {
+        var node = path.node;
+        if (node.body.type !== 'BlockStatement') {
+          node.body = t.blockStatement([t.returnStatement(node.body)]);
+          node.expression = false;
+        }
+        node.body.body.unshift(t.expressionStatement(t.callExpression(t.identifier('` + CallDepthFunc + `'), [])));
+      }
+    }
+  };
+})`
+
+// CheckCallDepth 在调用栈深度超过 max 时抛出 RangeError, 需要在 CallDepthFunc 中调用, e.g.
+//
+//   vm.Set(js.CallDepthFunc, func() { js.CheckCallDepth(vm, 1000) })
+func CheckCallDepth(vm *goja.Runtime, max int) {
+	if max <= 0 {
+		return
+	}
+	// 调用栈中包含 CallDepthFunc 自己
+	if len(vm.CaptureCallStack(max+2, nil)) <= max+1 {
+		return
+	}
+
+	e, err := vm.New(vm.Get("RangeError"), vm.ToValue(fmt.Sprintf("Maximum call depth exceeded (%d)", max)))
+	if err != nil {
+		panic(err)
+	}
+	panic(e)
+}
@@ -1,254 +1,511 @@
 package js
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/zbysir/goja-parser/ast"
-	"github.com/zbysir/goja-parser/token"
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/ast"
+	"github.com/gopenapi/gopenapi/internal/pkg/jsonordered"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
-// RunJs 运行一个js表达式, 返回值
-func RunJs(js string, getter func(name string) (interface{}, error)) (interface{}, error) {
-	express, source, err := parseExpress(js)
+// RunJs 运行一个js表达式, 返回值.
+// 表达式运行在goja中, 所以支持所有的js语法, 不支持的es6语法(如箭头函数, 模板字符串, 展开对象)会先通过babel转为es5.
+// 表达式中的变量(如 model)通过 getter 获取, getter 返回nil时会继续查找js中的全局变量.
+//
+// go的值在js中是宿主对象:
+//   - MemberGetter 实现 a.b 语法, e.g. model.Pet
+//   - IndexGetter 实现 a[b] 语法, e.g. model.Page[model.Pet], model.Map[string, model.Pet]
+//   - func(args ...interface{}) (interface{}, error) 是js中的方法, e.g. schema(model.Pet)
+// 返回值中的js对象与数组会转为 map[string]interface{} 与 []interface{}, 宿主对象则返回原本的go值.
+// limit 用于限制运行的时间, 为nil时不限制.
+func RunJs(js string, getter func(name string) (interface{}, error), limit Limiter) (interface{}, error) {
+	code, err := compileExpress(js)
 	if err != nil {
 		return nil, err
 	}
 
-	r := Runner{getter: getter, source: source}
+	r := &runner{
+		vm:     goja.New(),
+		getter: getter,
+		vars:   map[string]interface{}{},
+		errs:   map[string]error{},
+	}
+	r.vm.Set(indexFunc, r.index)
+	r.vm.Set(scopeVar, r.vm.NewDynamicObject(&scope{r: r}))
+
+	run := func() (goja.Value, error) {
+		return r.vm.RunString(code)
+	}
+	var v goja.Value
+	if limit != nil {
+		v, err = limit(r.vm, run)
+	} else {
+		v, err = run()
+	}
+	if err != nil {
+		return nil, r.unwrapError(js, err)
+	}
+
+	return export(v), nil
+}
 
-	return r.run(express)
+// Limiter 在限制中运行js, 如超时后调用 vm.Interrupt 中断 run
+type Limiter func(vm *goja.Runtime, run func() (goja.Value, error)) (goja.Value, error)
+
+type MemberGetter interface {
+	GetMember(k string) (interface{}, error)
+}
+
+// IndexGetter 用于实现 a[b] 语法, 如go泛型 model.Page[model.Pet]
+type IndexGetter interface {
+	GetIndex(args ...interface{}) (interface{}, error)
 }
 
-type Runner struct {
-	// Getter 是当js运行时遇到变量时调用的方法, 返回变量值
+// Func 是在js中可以调用的go方法
+type Func = func(args ...interface{}) (interface{}, error)
+
+const (
+	// scopeVar 是通过 with 语法提供变量的对象, 见 scope
+	scopeVar = "__gopenapi_scope"
+	// indexFunc 实现 a[b] 语法, 见 runner.index
+	indexFunc = "__gopenapi_index"
+)
+
+type runner struct {
+	vm *goja.Runtime
+	// getter 是当js运行时遇到变量时调用的方法, 返回变量值
 	getter func(name string) (interface{}, error)
-	// strict 表示是否是严格模式, 严格模式下, 遇到的错都会被return, 非严格模式下, Runner会尽量的返回nil, 而不报错.
-	strict bool
-	source string
+	// 缓存 getter 的结果, with 语法中每次读取变量都会调用 Has 与 Get
+	vars map[string]interface{}
+	errs map[string]error
+}
+
+// lookup 获取变量, 结果会被缓存
+func (r *runner) lookup(name string) (interface{}, error) {
+	if v, ok := r.vars[name]; ok {
+		return v, r.errs[name]
+	}
+	v, err := r.getter(name)
+	r.vars[name] = v
+	r.errs[name] = err
+	return v, err
 }
 
-func interface2ObjKey(i interface{}) string {
-	return fmt.Sprintf("%v", i)
+// scope 实现 goja.DynamicObject, 在 with 语法中提供 getter 中的变量
+type scope struct {
+	r *runner
 }
 
-type MemberGetter interface {
-	GetMember(k string) (interface{},error)
+func (s *scope) Get(key string) goja.Value {
+	v, err := s.r.lookup(key)
+	if err != nil {
+		panic(s.r.vm.NewGoError(err))
+	}
+	if v == nil {
+		return nil
+	}
+	return s.r.toValue(v)
 }
 
-func (r *Runner) run(expression ast.Expression) (interface{}, error) {
-	switch e := expression.(type) {
-	case *ast.ArrayLiteral:
-		var list []interface{}
-		for _, v := range e.Value {
-			switch v := v.(type) {
-			case *ast.SpreadElement:
-				arg, err := r.run(v.Argument)
-				if err != nil {
-					return nil, err
-				}
-				array, ok := arg.([]interface{})
-				if !ok {
-					err := fmt.Errorf("spread opeart only support on []interface, but: %T", arg)
-					if r.strict {
-						return nil, err
-					}
-					fmt.Printf("[err] %v\n", err)
-					return nil, nil
-				}
+func (s *scope) Has(key string) bool {
+	v, err := s.r.lookup(key)
+	// 有错误时也返回true, 在 Get 中抛出错误
+	return v != nil || err != nil
+}
 
-				for _, a := range array {
-					list = append(list, a)
-				}
-			default:
-				i, err := r.run(v)
-				if err != nil {
-					return nil, err
-				}
-				list = append(list, i)
+func (s *scope) Set(key string, val goja.Value) bool { return false }
+func (s *scope) Delete(key string) bool              { return false }
+func (s *scope) Keys() []string                      { return nil }
+
+// host 是go值在js中的宿主对象, 实现 goja.DynamicObject
+type host struct {
+	r *runner
+	v interface{}
+}
+
+func (h *host) Get(key string) goja.Value {
+	m, ok := h.v.(MemberGetter)
+	if !ok {
+		return nil
+	}
+	v, err := m.GetMember(key)
+	if err != nil {
+		panic(h.r.vm.NewGoError(err))
+	}
+	if v == nil {
+		return nil
+	}
+	return h.r.toValue(v)
+}
+
+func (h *host) Has(key string) bool {
+	m, ok := h.v.(MemberGetter)
+	if !ok {
+		return false
+	}
+	v, err := m.GetMember(key)
+	return err == nil && v != nil
+}
+
+func (h *host) Set(key string, val goja.Value) bool { return false }
+func (h *host) Delete(key string) bool              { return false }
+func (h *host) Keys() []string                      { return nil }
+
+// toValue 将go值转为js值
+func (r *runner) toValue(v interface{}) goja.Value {
+	switch v := v.(type) {
+	case nil:
+		return goja.Null()
+	case goja.Value:
+		return v
+	case Func:
+		return r.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+			args := make([]interface{}, len(call.Arguments))
+			for i, a := range call.Arguments {
+				args[i] = export(a)
+			}
+			res, err := v(args...)
+			if err != nil {
+				panic(r.vm.NewGoError(err))
 			}
+			return r.toValue(res)
+		})
+	case MemberGetter, IndexGetter:
+		return r.vm.NewDynamicObject(&host{r: r, v: v})
+	case []interface{}:
+		vs := make([]interface{}, len(v))
+		for i, item := range v {
+			vs[i] = r.toValue(item)
 		}
-		return list, nil
-	case *ast.ObjectLiteral:
-		var obj = map[string]interface{}{}
-		for _, p := range e.Value {
-			switch p := p.(type) {
-			case *ast.ObjectProperty:
-				key, err := r.run(p.Key)
-				if err != nil {
-					return nil, err
-				}
+		return r.vm.NewArray(vs...)
+	case map[string]interface{}:
+		o := r.vm.NewObject()
+		for k, item := range v {
+			_ = o.Set(k, r.toValue(item))
+		}
+		return o
+	case jsonordered.MapSlice:
+		o := r.vm.NewObject()
+		for _, item := range v {
+			_ = o.Set(item.Key, r.toValue(item.Val))
+		}
+		return o
+	}
+	return r.vm.ToValue(v)
+}
 
-				val, err := r.run(p.Value)
-				if err != nil {
-					return nil, err
+// index 实现 a[b] 语法, left 是go值时调用 IndexGetter, 否则与js中一样.
+// names 是参数的源码, 无法获取到值的变量(如go的基础类型 int, string)使用变量名.
+// isCallee 表示是 a[b]() 语法, 这时返回的方法需要绑定 this 为 a, e.g. 'abc'['toUpperCase']()
+func (r *runner) index(call goja.FunctionCall) goja.Value {
+	left := call.Argument(0)
+	argsObj := call.Argument(1).ToObject(r.vm)
+	args := make([]goja.Value, argsObj.Get("length").ToInteger())
+	names := make([]string, len(args))
+	namesObj := call.Argument(2).ToObject(r.vm)
+	for i := range args {
+		args[i] = argsObj.Get(strconv.Itoa(i))
+		names[i] = namesObj.Get(strconv.Itoa(i)).String()
+	}
+
+	if o, ok := left.(*goja.Object); ok {
+		if h, ok := o.Export().(*host); ok {
+			if g, ok := h.v.(IndexGetter); ok {
+				as := make([]interface{}, len(args))
+				for i, a := range args {
+					as[i] = export(a)
+					if as[i] == nil && isIdentifier(names[i]) {
+						as[i] = names[i]
+					}
 				}
-				obj[interface2ObjKey(key)] = val
-			case *ast.SpreadElement:
-				arg, err := r.run(p.Argument)
+				v, err := g.GetIndex(as...)
 				if err != nil {
-					return nil, err
+					panic(r.vm.NewGoError(err))
 				}
+				return r.toValue(v)
+			}
+		}
+	}
 
-				for k, v := range arg.(map[string]interface{}) {
-					obj[k] = v
+	if goja.IsUndefined(left) || goja.IsNull(left) {
+		panic(r.vm.NewTypeError("Cannot read property '%s' of %s", strings.Join(names, ", "), left))
+	}
+	// 逗号表达式的值是最后一个
+	v := left.ToObject(r.vm).Get(args[len(args)-1].String())
+	if !call.Argument(3).ToBoolean() {
+		return v
+	}
+	if f, ok := v.(*goja.Object); ok {
+		if _, ok := goja.AssertFunction(f); ok {
+			bind, _ := goja.AssertFunction(f.Get("bind"))
+			bound, err := bind(f, left)
+			if err != nil {
+				panic(err)
+			}
+			return bound
+		}
+	}
+	return v
+}
+
+// unwrapError 返回go方法中的原始错误, 其他错误加上表达式的源码
+func (r *runner) unwrapError(js string, err error) error {
+	var ex *goja.Exception
+	if errors.As(err, &ex) {
+		if o, ok := ex.Value().(*goja.Object); ok {
+			if v := o.Get("value"); v != nil {
+				if e, ok := v.Export().(error); ok {
+					return e
 				}
 			}
 		}
-		return obj, nil
-	case *ast.BooleanLiteral:
-		return e.Value, nil
-	case *ast.NumberLiteral:
-		return e.Value, nil
-	case *ast.StringLiteral:
-		return e.Value.String(), nil
-	case *ast.Identifier:
-		return r.getter(e.Name.String())
-	case *ast.BinaryExpression:
-
-		left, err := r.run(e.Left)
-		if err != nil {
-			return nil, err
+	}
+	return fmt.Errorf("run js '%s' err: %w", js, err)
+}
+
+// export 将js值转为go值, 宿主对象返回原本的go值
+func export(v goja.Value) interface{} {
+	if v == nil {
+		return nil
+	}
+	return unwrap(v.Export())
+}
+
+func unwrap(i interface{}) interface{} {
+	switch i := i.(type) {
+	case *host:
+		return i.v
+	case []interface{}:
+		for k, v := range i {
+			i[k] = unwrap(v)
 		}
-		right, err := r.run(e.Right)
-		if err != nil {
-			return nil, err
+	case map[string]interface{}:
+		for k, v := range i {
+			i[k] = unwrap(v)
 		}
+	}
+	return i
+}
 
-		switch e.Operator {
-		case token.PLUS:
-			return interfaceAdd(left, right), nil
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if !(c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			return false
 		}
-	case *ast.DotExpression:
-		left, err := r.run(e.Left)
-		if err != nil {
-			return nil, err
-		}
-		id := e.Identifier.Name.String()
-		switch left := left.(type) {
-		case map[string]interface{}:
-			return left[id], nil
-		case MemberGetter:
-			return left.GetMember(id)
-		default:
-			// TODO 非严格模式
-			// 思考: 非严格模式实际上可以不要
-			pkgName:=r.source[e.Idx0()-1:e.Left.Idx1()-1]
-			return nil, fmt.Errorf("can't read '%s' of type '%T' at script '%s', please make sure you import '%s' package", id, left, r.source[e.Idx0()-1:e.Idx1()-1], pkgName)
-		}
-
-	case *ast.CallExpression:
-		// 调用方法
-		funci, err := r.run(e.Callee)
+	}
+	return true
+}
+
+// 编译后的表达式, 同一个表达式(如 model.Pet)会被运行很多次, 特别是需要babel转换的表达式, 所以缓存起来.
+var compiled sync.Map
+
+// compileExpress 将表达式编译为可以在goja中运行的es5代码:
+//   - goja不支持的es6语法通过babel转为es5
+//   - a[b] 转为 __gopenapi_index(a, [b], ['b'], isCallee), 以支持go泛型
+//   - 使用 with 语法从 getter 中读取变量
+func compileExpress(js string) (string, error) {
+	if c, ok := compiled.Load(js); ok {
+		return c.(string), nil
+	}
+
+	source := fmt.Sprintf("(%s)", js)
+	p, err := goja.Parse("express", source)
+	if err != nil {
+		code, _, berr := transformExpress(source)
+		if berr != nil {
+			return "", fmt.Errorf("parse js '%s' err: %w", js, err)
+		}
+		source = code
+		p, err = goja.Parse("express", source)
 		if err != nil {
-			return nil, err
+			return "", fmt.Errorf("parse js '%s' err: %w", js, err)
 		}
+	}
+
+	code := fmt.Sprintf("with (%s) {%s\n}", scopeVar, rewriteIndex(source, p))
+	compiled.Store(js, code)
+	return code, nil
+}
 
-		fun, ok := funci.(func(arg ...interface{}) (interface{}, error))
+// transformExpress 使用babel将es6表达式转为es5, 表达式不能是严格模式, 因为需要使用 with 语法.
+func transformExpress(source string) (string, *SourceMap, error) {
+	b, err := newBabel()
+	if err != nil {
+		return "", nil, err
+	}
+	code, m, err := b.transformWith(source, "express", map[string]interface{}{
+		"plugins": []interface{}{"transform-object-rest-spread"},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.TrimPrefix(code, `"use strict";`), m, nil
+}
+
+// bracket 是 a[b] 语法在源码中的位置
+type bracket struct {
+	// 整个表达式的位置 [start, end)
+	start, end int
+	// a 的位置
+	left [2]int
+	// b 的位置, 逗号表达式有多个
+	members [][2]int
+	// 是否是方法调用 a[b](), 见 runner.index
+	isCallee bool
+}
+
+// rewriteIndex 将源码中所有的 a[b] 转为 __gopenapi_index(a, [b], ['b'], isCallee), 赋值语句中的 a[b] 除外
+func rewriteIndex(source string, p *ast.Program) string {
+	var bs []bracket
+	walkAst(reflect.ValueOf(p), nil, func(n, parent interface{}) {
+		e, ok := n.(*ast.BracketExpression)
 		if !ok {
-			return nil, r.newError(int(e.Idx0()), int(e.Idx1()),
-				fmt.Errorf("'%s' is not a function", r.expressionToString(e.Callee)))
+			return
+		}
+		switch parent := parent.(type) {
+		case *ast.AssignExpression:
+			if parent.Left == e {
+				return
+			}
+		case *ast.UnaryExpression:
+			// ++, --, delete
+			return
 		}
-		args := make([]interface{}, len(e.ArgumentList))
 
-		for i, a := range e.ArgumentList {
-			arg, err := r.run(a)
-			if err != nil {
-				return nil, err
+		// 语法树中没有括号, 括号中的 a (e.g. ({})['a']) 的位置不包含左括号, 需要向前找到匹配的左括号
+		start, leftEnd := int(e.Left.Idx0())-1, int(e.LeftBracket)-1
+		for n := unmatchedParens(source[start:leftEnd]); n > 0; n-- {
+			i := start - 1
+			for i >= 0 && strings.ContainsRune(" \t\r\n", rune(source[i])) {
+				i--
 			}
-			args[i] = arg
+			if i < 0 || source[i] != '(' {
+				break
+			}
+			start = i
 		}
-		return fun(args...)
-	default:
-		panic(fmt.Sprintf("uncased expression Type :%T, %+v", e, e))
-	}
-	return nil, nil
-}
+		b := bracket{
+			start: start,
+			end:   int(e.RightBracket),
+			left:  [2]int{start, leftEnd},
+		}
+		if call, ok := parent.(*ast.CallExpression); ok && call.Callee == e {
+			b.isCallee = true
+		}
+		members := []ast.Expression{e.Member}
+		if seq, ok := e.Member.(*ast.SequenceExpression); ok {
+			members = seq.Sequence
+		}
+		for i, m := range members {
+			// 部分节点(如三元表达式)的 Idx1 不准确, 所以使用下一个成员的位置作为结束
+			start, end := int(m.Idx0())-1, int(e.RightBracket)-1
+			if i == 0 {
+				start = int(e.LeftBracket)
+			}
+			if i+1 < len(members) {
+				end = int(members[i+1].Idx0()) - 1
+				end = strings.LastIndexByte(source[:end], ',')
+			}
+			b.members = append(b.members, [2]int{start, end})
+		}
+		bs = append(bs, b)
+	})
 
-func (r *Runner) dotExpressionToString(de *ast.DotExpression) string {
-	key := de.Identifier.Name.String()
-	switch l := de.Left.(type) {
-	case *ast.Identifier:
-		key = l.Name.String() + "." + key
-	default:
-		panic(fmt.Sprintf("uncased DotExpression.Left Type :%T", l))
+	sort.Slice(bs, func(i, j int) bool {
+		if bs[i].start != bs[j].start {
+			return bs[i].start < bs[j].start
+		}
+		return bs[i].end > bs[j].end
+	})
+
+	var render func(start, end int) string
+	render = func(start, end int) string {
+		var sb strings.Builder
+		for _, b := range bs {
+			if b.start < start || b.end > end {
+				continue
+			}
+			sb.WriteString(source[start:b.start])
+			var args, names []string
+			for _, m := range b.members {
+				arg := render(m[0], m[1])
+				name := strings.TrimSpace(source[m[0]:m[1]])
+				// 未定义的变量(如 int)不能抛出错误
+				if isIdentifier(name) {
+					arg = fmt.Sprintf("(typeof %s === 'undefined' ? undefined : %s)", name, name)
+				}
+				args = append(args, arg)
+				names = append(names, strconv.Quote(name))
+			}
+			sb.WriteString(fmt.Sprintf("%s(%s, [%s], [%s], %t)", indexFunc, render(b.left[0], b.left[1]),
+				strings.Join(args, ", "), strings.Join(names, ", "), b.isCallee))
+			start = b.end
+		}
+		sb.WriteString(source[start:end])
+		return sb.String()
 	}
-	return key
+
+	return render(0, len(source))
 }
 
-// 用于debug
-func (r *Runner) expressionToString(de ast.Expression) string {
-	switch l := de.(type) {
-	case *ast.DotExpression:
-		return r.expressionToString(l.Left) + "." + r.expressionToString(&l.Identifier)
-	case *ast.Identifier:
-		return l.Name.String()
-	case *ast.CallExpression:
-		args:=make([]string, len(l.ArgumentList))
-		for i, v := range l.ArgumentList {
-			args[i] = r.expressionToString(v)
+// unmatchedParens 返回源码中没有匹配的右括号的数量, 会忽略字符串中的括号
+func unmatchedParens(s string) int {
+	depth, n := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			} else {
+				n++
+			}
 		}
-		return r.expressionToString(l.Callee)+"("+strings.Join(args,",")+")"
-	default:
-		panic(fmt.Sprintf("uncased expression Type :%T", l))
 	}
-	return ""
+	return n
 }
 
-func (r *Runner) newError(start, end int, err error) error {
-	return fmt.Errorf("run js '%s' err: %w", r.source[start-1:end-1], err)
-}
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
 
-func interfaceAdd(a, b interface{}) interface{} {
-	an, ok := isNumber(a)
-	if !ok {
-		return interfaceToStr(a) + interfaceToStr(b)
+// walkAst 遍历语法树中的所有节点, cb 的参数是节点与它的父节点
+func walkAst(v reflect.Value, parent interface{}, cb func(n, parent interface{})) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		if v.Kind() == reflect.Ptr && v.Type().Implements(nodeType) {
+			n := v.Interface()
+			cb(n, parent)
+			parent = n
+		}
+		walkAst(v.Elem(), parent, cb)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			walkAst(v.Field(i), parent, cb)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walkAst(v.Index(i), parent, cb)
+		}
 	}
-	bn, ok := isNumber(b)
-	if !ok {
-		return interfaceToStr(a) + interfaceToStr(b)
-	}
-
-	return an + bn
-}
-
-func isNumber(s interface{}) (d float64, is bool) {
-	if s == nil {
-		return 0, false
-	}
-	switch a := s.(type) {
-	case int:
-		return float64(a), true
-	case int32:
-		return float64(a), true
-	case int64:
-		return float64(a), true
-	case float64:
-		return a, true
-	case float32:
-		return float64(a), true
-	default:
-		return 0, false
-	}
-}
-
-func interfaceToStr(s interface{}) (d string) {
-	switch a := s.(type) {
-	case string:
-		d = a
-	case int:
-		d = strconv.FormatInt(int64(a), 10)
-	case int32:
-		d = strconv.FormatInt(int64(a), 10)
-	case int64:
-		d = strconv.FormatInt(a, 10)
-	case float64:
-		d = strconv.FormatFloat(a, 'f', -1, 64)
-	default:
-		bs, _ := json.Marshal(a)
-		d = string(bs)
-	}
-
-	return
 }
@@ -2,9 +2,39 @@ package js
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
+type testPkg struct{}
+
+func (testPkg) GetMember(k string) (interface{}, error) {
+	if k == "Missing" {
+		return nil, fmt.Errorf("'%s' is not found", k)
+	}
+	return testType{name: k}, nil
+}
+
+type testType struct {
+	name string
+	args []interface{}
+}
+
+func (t testType) GetIndex(args ...interface{}) (interface{}, error) {
+	return testType{name: t.name, args: args}, nil
+}
+
+func (t testType) String() string {
+	if len(t.args) == 0 {
+		return t.name
+	}
+	var args []string
+	for _, a := range t.args {
+		args = append(args, fmt.Sprint(a))
+	}
+	return t.name + "[" + strings.Join(args, ",") + "]"
+}
+
 func TestRunJs(t *testing.T) {
 	getter := func(name string) (interface{}, error) {
 		switch name {
@@ -14,13 +44,11 @@ func TestRunJs(t *testing.T) {
 			return map[string]interface{}{
 				"a": 3,
 			}, nil
-		case "getter":
-			return func(k string) (v interface{}) {
-				switch k {
-				case "get1000":
-					return 1000
-				}
-				return 0
+		case "model":
+			return testPkg{}, nil
+		case "schema":
+			return func(args ...interface{}) (interface{}, error) {
+				return fmt.Sprintf("schema(%v)", args[0]), nil
 			}, nil
 		}
 		return nil, nil
@@ -32,25 +60,86 @@ func TestRunJs(t *testing.T) {
 	}{
 		{
 			js:   "obj.a+1",
-			want: "4 float64",
+			want: "4 int64",
+		},
+		{
+			js:   "obj['a'] * 1.5",
+			want: "4.5 float64",
+		},
+		{
+			js:   "a > 1 ? -a : a",
+			want: "-2 int64",
 		},
 		{
-			js:   "getter.get1000 + 1",
-			want: "1001 float64",
+			js:   "`a is ${a}`",
+			want: "a is 2 string",
+		},
+		{
+			js:   "[1, 2].map((i) => i * a)",
+			want: "[2 4] []interface {}",
+		},
+		{
+			js:   "[1, 2]['map'](i => i * 2)",
+			want: "[2 4] []interface {}",
+		},
+		{
+			js:   "'abc'['toUpperCase']()",
+			want: "ABC string",
+		},
+		{
+			js:   "({v: 7, f: function() { return this.v }})['f']()",
+			want: "7 int64",
+		},
+		{
+			js:   "((obj))['a'] + ({')': 1})[')']",
+			want: "4 int64",
+		},
+		{
+			js:   "(model.Page)[model.Pet]",
+			want: "Page[Pet] js.testType",
+		},
+		{
+			js:   "{...obj, b: 1}",
+			want: "map[a:3 b:1] map[string]interface {}",
+		},
+		{
+			js:   "model.Pet",
+			want: "Pet js.testType",
+		},
+		{
+			js:   "model.Page[model.Pet]",
+			want: "Page[Pet] js.testType",
+		},
+		{
+			js:   "model.Map[string, model.Page[int]]",
+			want: "Map[string,Page[int]] js.testType",
+		},
+		{
+			js:   "[schema(model.Pet), model.Tag]",
+			want: "[schema(Pet) Tag] []interface {}",
 		},
 	}
 
 	for _, c := range cases {
-		v, err := RunJs(c.js, getter)
+		v, err := RunJs(c.js, getter, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
 
 		got := fmt.Sprintf("%+v %T", v, v)
 		if got != c.want {
-			t.Fatalf("want: %s, got: %s", c.want, got)
+			t.Fatalf("js: %s, want: %s, got: %s", c.js, c.want, got)
 		}
 	}
 
-	t.Log("ok")
+	// go方法中的错误会原样返回
+	_, err := RunJs("model.Missing", getter, nil)
+	if err == nil || err.Error() != "'Missing' is not found" {
+		t.Fatalf("want error of GetMember, got: %v", err)
+	}
+
+	_, err = RunJs("b.c", getter, nil)
+	if err == nil || !strings.Contains(err.Error(), "b is not defined") {
+		t.Fatalf("want ReferenceError, got: %v", err)
+	}
 }
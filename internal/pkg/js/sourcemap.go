@@ -0,0 +1,96 @@
+package js
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
+	"path"
+	"strings"
+)
+
+// configOpts 是转换配置文件时额外的选项: 生成 source map, 支持对象展开语法(常用 {...a, b} 合并预设),
+// 并在每个方法的开头检查调用深度, 见 CallDepthFunc.
+var configOpts = map[string]interface{}{
+	"sourceMaps": true,
+	"plugins":    []interface{}{"transform-object-rest-spread", customPlugin(callDepthPlugin)},
+}
+
+// transformConfig 使用 configOpts 转换配置文件
+func transformConfig(src, filename string) (code string, srcmap *SourceMap, err error) {
+	var b *babel
+	if b, err = newBabel(); err != nil {
+		return
+	}
+
+	return b.transformWith(src, filename, configOpts)
+}
+
+// Compile 编译babel转换后的代码, srcmap 不为空时, 错误栈中的位置会被映射回源文件的行与列.
+// filename 应该是绝对路径, srcmap 中的 sources 是相对于它所在的目录的.
+func Compile(filename, code string, srcmap *SourceMap) (*goja.Program, error) {
+	var opts []parser.Option
+	if srcmap != nil && srcmap.Mappings != "" {
+		bs, err := json.Marshal(srcmap)
+		if err != nil {
+			return nil, err
+		}
+		mapFile := path.Base(filename) + ".map"
+		code += "\n//# sourceMappingURL=" + mapFile
+		opts = append(opts, parser.WithSourceMapLoader(func(string) ([]byte, error) {
+			return bs, nil
+		}))
+	}
+
+	ast, err := goja.Parse(filename, code, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return goja.CompileAST(ast, false)
+}
+
+// CodeFrame 返回源码中 line:column(从1开始) 附近的代码, 格式与babel的错误相同, e.g.
+//
+//     5 |     let {a} = value
+//   > 6 |     return a.b.c
+//       |            ^
+//     7 |   }
+func CodeFrame(src string, line, column int) string {
+	lines := strings.Split(src, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	start, end := line-2, line+2
+	if start < 1 {
+		start = 1
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	width := len(fmt.Sprint(end))
+
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		mark := " "
+		if i == line {
+			mark = ">"
+		}
+		code := strings.TrimRight(lines[i-1], "\r")
+		fmt.Fprintf(&b, "%s %*d | %s\n", mark, width, i, code)
+		if i == line && column > 0 {
+			// tab 需要保留, 否则箭头的位置会不对
+			pad := []rune(code)
+			if column-1 < len(pad) {
+				pad = pad[:column-1]
+			}
+			for j, r := range pad {
+				if r != '\t' {
+					pad[j] = ' '
+				}
+			}
+			fmt.Fprintf(&b, "  %*s | %s^\n", width, "", string(pad))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -1,6 +1,7 @@
 package js
 
 import (
+	"crypto/sha256"
 	rice "github.com/GeertJohan/go.rice"
 	"github.com/dop251/goja"
 	"github.com/mitchellh/mapstructure"
@@ -15,28 +16,50 @@ type babel struct {
 	vm        *goja.Runtime
 	this      goja.Value
 	transform goja.Callable
-	mutex     sync.Mutex // TODO: cache goja.CompileAST() in an init() function?
+	// 编译过的自定义插件, 见 customPlugin
+	plugins map[customPlugin]goja.Value
+	mutex   sync.Mutex // TODO: cache goja.CompileAST() in an init() function?
 }
 
+// customPlugin 是自定义babel插件的源码, 可以放在选项的 plugins 中, 转换时才会被编译.
+// 用源码而不是编译后的值作为选项, 是为了让选项可以被序列化, 见 cacheKey.
+type customPlugin string
+
 var once sync.Once
 var globalBabel *babel
 
+// babelSource 返回内嵌的 babel.min.js
+func babelSource() string {
+	conf := rice.Config{
+		LocateOrder: []rice.LocateMethod{rice.LocateEmbedded},
+	}
+	return conf.MustFindBox("lib").MustString("babel.min.js")
+}
+
+var babelHashOnce sync.Once
+var babelHash []byte
+
+// babelSourceHash 返回 babel.min.js 的sha256, babel 版本不同时输出也可能不同
+func babelSourceHash() []byte {
+	babelHashOnce.Do(func() {
+		h := sha256.Sum256([]byte(babelSource()))
+		babelHash = h[:]
+	})
+	return babelHash
+}
+
 func newBabel() (*babel, error) {
 	var err error
 
 	once.Do(func() {
-		conf := rice.Config{
-			LocateOrder: []rice.LocateMethod{rice.LocateEmbedded},
-		}
-		babelSrc := conf.MustFindBox("lib").MustString("babel.min.js")
 		vm := goja.New()
-		if _, err = vm.RunString(babelSrc); err != nil {
+		if _, err = vm.RunString(babelSource()); err != nil {
 			return
 		}
 
 		this := vm.Get("Babel")
 		bObj := this.ToObject(vm)
-		globalBabel = &babel{vm: vm, this: this}
+		globalBabel = &babel{vm: vm, this: this, plugins: map[customPlugin]goja.Value{}}
 		if err = vm.ExportTo(bObj.Get("transform"), &globalBabel.transform); err != nil {
 			return
 		}
@@ -56,12 +79,40 @@ var DefaultOpts = map[string]interface{}{
 }
 
 func (b *babel) Transform(src, filename string) (string, *SourceMap, error) {
-	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	return b.transformWith(src, filename, nil)
+}
+
+// mergeOpts 返回 DefaultOpts 与 extra 合并后的选项, extra 会覆盖 DefaultOpts 中的同名选项
+func mergeOpts(extra map[string]interface{}) map[string]interface{} {
 	opts := make(map[string]interface{})
 	for k, v := range DefaultOpts {
 		opts[k] = v
 	}
+	for k, v := range extra {
+		opts[k] = v
+	}
+	return opts
+}
+
+// transformWith 使用额外的选项转换代码, 会覆盖 DefaultOpts 中的同名选项
+func (b *babel) transformWith(src, filename string, extra map[string]interface{}) (string, *SourceMap, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	opts := mergeOpts(extra)
+	if plugins, ok := opts["plugins"].([]interface{}); ok {
+		ps := make([]interface{}, len(plugins))
+		for i, p := range plugins {
+			ps[i] = p
+			if c, ok := p.(customPlugin); ok {
+				v, err := b.plugin(c)
+				if err != nil {
+					return "", nil, err
+				}
+				ps[i] = v
+			}
+		}
+		opts["plugins"] = ps
+	}
 	opts["filename"] = filename
 
 	v, err := b.transform(b.this, b.vm.ToValue(src), b.vm.ToValue(opts))
@@ -85,13 +136,26 @@ func (b *babel) Transform(src, filename string) (string, *SourceMap, error) {
 	return code, &srcMap, err
 }
 
+// plugin 返回编译后的自定义插件
+func (b *babel) plugin(p customPlugin) (goja.Value, error) {
+	if v, ok := b.plugins[p]; ok {
+		return v, nil
+	}
+	v, err := b.vm.RunString(string(p))
+	if err != nil {
+		return nil, err
+	}
+	b.plugins[p] = v
+	return v, nil
+}
+
 type SourceMap struct {
-	Version    int
-	File       string
-	SourceRoot string
-	Sources    []string
-	Names      []string
-	Mappings   string
+	Version    int      `json:"version"`
+	File       string   `json:"file"`
+	SourceRoot string   `json:"sourceRoot"`
+	Sources    []string `json:"sources"`
+	Names      []string `json:"names"`
+	Mappings   string   `json:"mappings"`
 }
 
 func Transform(src, filename string) (code string, srcmap *SourceMap, err error) {
@@ -0,0 +1,358 @@
+package openapi
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// x-$autoComponents 支持的命名方式, 以 github.com/gopenapi/gopenapi/internal/model.Pet 为例:
+//   - Name: Pet
+//   - pkg.Name: model.Pet
+//   - PkgName: ModelPet
+const (
+	namingName    = "Name"
+	namingPkgName = "pkg.Name"
+	namingPascal  = "PkgName"
+)
+
+// autoSchema 是自动注册的component
+type autoSchema struct {
+	// go def path, e.g. github.com/gopenapi/gopenapi/internal/model.Pet
+	key string
+	// name 在所有的类型都注册之后才确定, 在这之前是 autoPlaceholder, 见 resolveAutoNames
+	name string
+}
+
+// autoPlaceholderPrefix 是 autoPlaceholder 的前缀
+const autoPlaceholderPrefix = "gopenapi-auto("
+
+// autoPlaceholder 返回自动注册的component在确定名字之前使用的名字.
+// 引入路径中不会有括号, 所以它不会是另一个占位符的一部分.
+func autoPlaceholder(key string) string {
+	return autoPlaceholderPrefix + key + ")"
+}
+
+const schemasPrefix = "components/schemas/"
+
+// loadAutoComponents 读取并删除 components 下的 x-$autoComponents 配置, 开启后所有遇到的结构体都会被注册到components中.
+// e.g.
+//   components:
+//     x-$autoComponents: PkgName
+// 值为 true 时使用 Name 命名方式.
+func (o *OpenApi) loadAutoComponents(kv []yaml.MapItem) ([]yaml.MapItem, error) {
+	for i, item := range kv {
+		if item.Key != "components" {
+			continue
+		}
+		components, ok := item.Value.([]yaml.MapItem)
+		if !ok {
+			return kv, nil
+		}
+
+		var rest []yaml.MapItem
+		for _, c := range components {
+			if c.Key != "x-$autoComponents" {
+				rest = append(rest, c)
+				continue
+			}
+
+			switch v := c.Value.(type) {
+			case bool:
+				if v {
+					o.autoComponents = namingName
+				}
+			case string:
+				switch v {
+				case namingName, namingPkgName, namingPascal:
+					o.autoComponents = v
+				default:
+					return nil, fmt.Errorf("invalid value of x-$autoComponents: '%s', it should be one of %s, %s, %s", v, namingName, namingPkgName, namingPascal)
+				}
+			default:
+				return nil, fmt.Errorf("invalid value of x-$autoComponents: %v", v)
+			}
+		}
+		kv[i].Value = rest
+	}
+
+	return kv, nil
+}
+
+// autoComponent 注册类型为component, 返回yaml中的路径(e.g. components/schemas/Pet).
+// 只注册本项目中的结构体与接口, 基础类型(如枚举)依然是内联的.
+func (o *OpenApi) autoComponent(key string, s Schema) (yamlKey string, ok bool) {
+	if o.autoComponents == "" {
+		return
+	}
+	switch s.(type) {
+	case *ObjectSchema, AllOfSchema, *OneOfSchema:
+	default:
+		return
+	}
+	return o.registerComponent(key)
+}
+
+// recursiveComponent 注册递归的类型为component, 这样才能通过$ref引用自身.
+// 和 x-$autoComponents 不同的是, 它总是开启的.
+func (o *OpenApi) recursiveComponent(key string) (yamlKey string, ok bool) {
+	return o.registerComponent(key)
+}
+
+// registerComponent 注册类型为component, 只支持本项目中的类型, 因为需要通过 x-$schema 生成component.
+func (o *OpenApi) registerComponent(key string) (yamlKey string, ok bool) {
+	if _, inProject := o.goparse.FormatPath(key); !inProject {
+		return
+	}
+
+	// 名字与其他类型有关(冲突时需要加上包名), 所以先使用占位符
+	name := autoPlaceholder(key)
+	o.autoSchemas = append(o.autoSchemas, autoSchema{key: key, name: name})
+	yamlKey = schemasPrefix + name
+	o.schemasDef[key] = yamlKey
+	return yamlKey, true
+}
+
+// componentNames 为类型生成component的名字, 返回 key => 名字. used 是已经被使用了的名字.
+// 类型按完整的引入路径排序后依次命名, 冲突时排在后面的类型使用较长的名字,
+// 所以名字只与有哪些类型有关, 与遇到它们的顺序无关.
+// e.g. PkgName 方式时, a/model.Pet 与 b/model.Pet 的名字分别是 ModelPet 与 BModelPet
+func (o *OpenApi) componentNames(keys []string, used map[string]bool) map[string]string {
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+
+	names := map[string]string{}
+	for _, key := range sorted {
+		name := o.componentName(key, used)
+		used[name] = true
+		names[key] = name
+	}
+	return names
+}
+
+// componentName 根据命名方式生成component的名字.
+// 当名字已经被使用时, 会依次在前面加上上一级的目录名, 直到不重复为止.
+func (o *OpenApi) componentName(key string, used map[string]bool) string {
+	pkg, _ := splitPkgPath(key)
+	if i := strings.IndexByte(pkg, '['); i != -1 {
+		pkg = pkg[:i]
+	}
+	segments := strings.Split(strings.TrimSuffix(pkg, "/"), "/")
+	name := schemaName(key)
+
+	// 没有开启 x-$autoComponents 时(递归的类型)使用 Name 方式
+	naming := o.autoComponents
+	if naming == "" {
+		naming = namingName
+	}
+
+	// 包名的个数, Name 方式在冲突之前不需要包名
+	n := 1
+	if naming == namingName {
+		n = 0
+	}
+	for ; n <= len(segments); n++ {
+		c := joinComponentName(naming, segments[len(segments)-n:], name)
+		if !used[c] {
+			return c
+		}
+	}
+
+	// 整个路径都一样的情况理论上不存在, 这里只是为了保证名字不重复
+	full := joinComponentName(naming, segments, name)
+	for i := 2; ; i++ {
+		c := full + strconv.Itoa(i)
+		if !used[c] {
+			return c
+		}
+	}
+}
+
+// joinComponentName 拼接包名与类型名, pkg.Name 方式使用.分割, 其他方式使用驼峰
+func joinComponentName(naming string, pkgs []string, name string) string {
+	if naming == namingPkgName {
+		var ss []string
+		for _, p := range pkgs {
+			ss = append(ss, componentNameChars(p, false))
+		}
+		return strings.Join(append(ss, name), ".")
+	}
+
+	var sb strings.Builder
+	for _, p := range pkgs {
+		sb.WriteString(componentNameChars(p, true))
+	}
+	sb.WriteString(name)
+	return sb.String()
+}
+
+// componentNameChars 删除component名字中不允许的字符(只允许 a-zA-Z0-9.-_), pascal 为true时转为驼峰
+// e.g. github.com => GithubCom, go-kit => GoKit
+func componentNameChars(s string, pascal bool) string {
+	var sb strings.Builder
+	upper := pascal
+	for _, r := range s {
+		isChar := r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+		if !isChar && (pascal || !(r == '_' || r == '-')) {
+			upper = pascal
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// completeAutoComponents 将被引用的自动注册的component(包括递归的类型)添加到 components.schemas 中.
+// component 中可能引用其他的component, 所以需要重复处理直到没有新的引用.
+func (o *OpenApi) completeAutoComponents(kv []yaml.MapItem) ([]yaml.MapItem, error) {
+	if len(o.autoSchemas) == 0 {
+		return kv, nil
+	}
+
+	refs := map[string]bool{}
+	collectRefs(kv, refs)
+
+	var schemas []yaml.MapItem
+	added := map[string]bool{}
+	var keys []string
+	for {
+		var news []yaml.MapItem
+		for _, a := range o.autoSchemas {
+			if added[a.name] || !isReferenced(refs, a.name) {
+				continue
+			}
+			added[a.name] = true
+			keys = append(keys, a.key)
+
+			s, err := o.completeYaml([]yaml.MapItem{{Key: "x-$schema", Value: a.key}}, []string{"components", "schemas", a.name})
+			if err != nil {
+				return nil, err
+			}
+			news = append(news, yaml.MapItem{Key: a.name, Value: s})
+		}
+		if len(news) == 0 {
+			break
+		}
+		collectRefs(news, refs)
+		schemas = append(schemas, news...)
+	}
+
+	kv, schemas = o.resolveAutoNames(kv, schemas, keys)
+	sort.SliceStable(schemas, func(i, j int) bool {
+		return schemas[i].Key.(string) < schemas[j].Key.(string)
+	})
+
+	return appendSchemas(kv, schemas), nil
+}
+
+// resolveAutoNames 在所有被引用的类型都注册之后确定它们的名字, 并替换文档与 schemas 中的占位符.
+func (o *OpenApi) resolveAutoNames(kv []yaml.MapItem, schemas []yaml.MapItem, keys []string) ([]yaml.MapItem, []yaml.MapItem) {
+	// 文档中已经定义了的component不能再使用
+	used := map[string]bool{}
+	for name := range componentSchemas(kv) {
+		used[name] = true
+	}
+	for _, v := range o.schemasDef {
+		if name := strings.TrimPrefix(v, schemasPrefix); !strings.HasPrefix(name, autoPlaceholderPrefix) {
+			used[name] = true
+		}
+	}
+
+	names := o.componentNames(keys, used)
+	var oldnew []string
+	for i, a := range o.autoSchemas {
+		name, ok := names[a.key]
+		if !ok {
+			continue
+		}
+		oldnew = append(oldnew, a.name, name)
+		o.autoSchemas[i].name = name
+		o.schemasDef[a.key] = schemasPrefix + name
+	}
+	r := strings.NewReplacer(oldnew...)
+
+	return replaceYamlStrings(kv, r).([]yaml.MapItem), replaceYamlStrings(schemas, r).([]yaml.MapItem)
+}
+
+// replaceYamlStrings 替换yaml中所有的字符串(包括key), 如 $ref 中的占位符
+func replaceYamlStrings(i interface{}, r *strings.Replacer) interface{} {
+	switch i := i.(type) {
+	case []yaml.MapItem:
+		x := make([]yaml.MapItem, len(i))
+		for j, item := range i {
+			x[j] = yaml.MapItem{Key: replaceYamlStrings(item.Key, r), Value: replaceYamlStrings(item.Value, r)}
+		}
+		return x
+	case []interface{}:
+		x := make([]interface{}, len(i))
+		for j, item := range i {
+			x[j] = replaceYamlStrings(item, r)
+		}
+		return x
+	case string:
+		return r.Replace(i)
+	}
+	return i
+}
+
+// isReferenced 返回component是否被引用, 引用它的只读/只写变体(e.g. PetRead)也算
+func isReferenced(refs map[string]bool, name string) bool {
+	ref := "#/" + schemasPrefix + name
+	return refs[ref] || refs[ref+readSuffix] || refs[ref+writeSuffix]
+}
+
+// collectRefs 查找yaml中所有的$ref
+func collectRefs(i interface{}, refs map[string]bool) {
+	switch i := i.(type) {
+	case []yaml.MapItem:
+		for _, item := range i {
+			if item.Key == "$ref" {
+				if ref, ok := item.Value.(string); ok {
+					refs[ref] = true
+				}
+				continue
+			}
+			collectRefs(item.Value, refs)
+		}
+	case []interface{}:
+		for _, item := range i {
+			collectRefs(item, refs)
+		}
+	}
+}
+
+// appendSchemas 将schemas添加到 components.schemas 的最后, 没有components时会创建它
+func appendSchemas(kv []yaml.MapItem, schemas []yaml.MapItem) []yaml.MapItem {
+	if len(schemas) == 0 {
+		return kv
+	}
+
+	for i, item := range kv {
+		if item.Key != "components" {
+			continue
+		}
+		components, _ := item.Value.([]yaml.MapItem)
+		for j, c := range components {
+			if c.Key == "schemas" {
+				exist, _ := c.Value.([]yaml.MapItem)
+				components[j].Value = append(exist, schemas...)
+				kv[i].Value = components
+				return kv
+			}
+		}
+		kv[i].Value = append(components, yaml.MapItem{Key: "schemas", Value: schemas})
+		return kv
+	}
+
+	return append(kv, yaml.MapItem{
+		Key:   "components",
+		Value: []yaml.MapItem{{Key: "schemas", Value: schemas}},
+	})
+}
@@ -0,0 +1,89 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Directive 是用go实现的 x-$ 语法, 适合写一些在js中不方便实现的语法, 如读取路由表或者构建信息.
+// 使用 RegisterDirective 注册后, completeYaml 会用它处理对应的key, 而不会调用 gopenapi.conf.js 中的 filter.
+// 在 internal 之外使用 pkg/gopenapi 中的 WithDirectives 注册.
+type Directive interface {
+	// Name 是不带 x-$ 前缀的名字, e.g. build 对应 x-$build
+	Name() string
+	// Expand 返回key的新值, 与 filter 的返回值一样: 对象会展开到父级中, 其他类型保留原有的key, nil 会删除这个key.
+	// value 是yaml中的值(已经处理过其中的 x-$ 语法), 对象是有序的 jsonordered.MapSlice.
+	// route 是key的路径, e.g. [paths /pets get]
+	Expand(ctx *DirectiveContext, value interface{}, route []string) (interface{}, error)
+}
+
+// DirectiveContext 是调用 Directive 时的上下文
+type DirectiveContext struct {
+	// Key 是完整的key, e.g. x-$build
+	Key string
+	// Version 是正在处理的openapi文档的版本, 如 3.0.1
+	Version string
+
+	o *OpenApi
+}
+
+// Parse 与 gopenapi.conf.js 中的 go.parse 相同, 解析go定义的注释与schema.
+// path: e.g. github.com/gopenapi/gopenapi/internal/model.Pet
+func (c *DirectiveContext) Parse(path string) (g *GoStruct, exist bool, err error) {
+	c.o.goDef = path
+	return c.o.getGoStruct(path)
+}
+
+// RegisterDirective 注册用go实现的 x-$ 语法, 需要在 CompleteYaml 之前调用.
+// 同一个名字只能注册一次.
+func (o *OpenApi) RegisterDirective(ds ...Directive) error {
+	if o.directives == nil {
+		o.directives = map[string]Directive{}
+	}
+	for _, d := range ds {
+		name := d.Name()
+		if name == "" {
+			return errors.New("register directive err: empty name")
+		}
+		if strings.HasPrefix(name, "x-$") {
+			return fmt.Errorf("register directive err: name '%s' should not have prefix 'x-$'", name)
+		}
+		if _, ok := o.directives[name]; ok {
+			return fmt.Errorf("register directive err: 'x-$%s' is already registered", name)
+		}
+		o.directives[name] = d
+	}
+	return nil
+}
+
+// runDirective 调用key对应的 Directive, 返回结果的json. 没有注册时 ok 为false, 应该交给 filter 处理.
+func (o *OpenApi) runDirective(key string, value interface{}, keyRouter []string) (bs []byte, ok bool, err error) {
+	d, ok := o.directives[strings.TrimPrefix(key, "x-$")]
+	if !ok {
+		return
+	}
+
+	parentDef := o.goDef
+	o.goDef = ""
+	defer func() { o.goDef = parentDef }()
+
+	r, err := d.Expand(&DirectiveContext{Key: key, Version: o.version, o: o}, value, keyRouter)
+	if err != nil {
+		err = fmt.Errorf("run directive '%s' err: %w\n  yaml: %s", key, err, strings.Join(keyRouter, "."))
+		if o.goDef != "" {
+			err = fmt.Errorf("%w\n  go: %s", err, o.goDef)
+		}
+		return
+	}
+	if r == nil {
+		return []byte(`{}`), true, nil
+	}
+
+	bs, err = json.Marshal(r)
+	if err != nil {
+		err = fmt.Errorf("run directive '%s' err: marshal result: %w", key, err)
+	}
+	return
+}
@@ -0,0 +1,128 @@
+package openapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EnvelopeConfig 是 gopenapi.conf.js 中的 envelope 配置, 用于将响应包装为统一的格式, e.g. {code, msg, data}.
+// 信封是一个结构体, 其中注释了 $payload 的字段会被替换为响应的schema:
+//
+//   type Resp struct {
+//     Code int    `json:"code"`
+//     Msg  string `json:"msg"`
+//     // $payload: true
+//     Data interface{} `json:"data"`
+//   }
+type EnvelopeConfig struct {
+	// Type 是 wrap() 默认使用的信封, e.g. ./internal/model.Resp
+	Type string `json:"type"`
+	// Auto 为true时所有2xx的响应都会使用 Type 包装
+	Auto bool `json:"auto"`
+	// Error 是错误响应(4xx, 5xx, default)使用的信封, 不为空时总是自动包装
+	Error string `json:"error"`
+}
+
+// wrapSchema 实现 wrap() 方法, 使用信封包装payload, 没有指定信封时使用配置中的 envelope.type.
+// e.g.
+//   response: wrap(model.Pet)
+//   response: {400: wrap(model.Error, model.ErrResp)}
+func (o *OpenApi) wrapSchema(payload interface{}, envelope interface{}) (Schema, error) {
+	if envelope == nil {
+		if o.envelope.Type == "" {
+			return nil, fmt.Errorf("wrap(): no envelope is specified, set 'envelope.type' in gopenapi.conf.js or pass it as the second argument")
+		}
+		envelope = o.envelope.Type
+	}
+
+	env, name, err := o.envelopeSchema(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := o.anyToSchema(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	r := env.clone()
+	for i, p := range r.Properties {
+		if propName(p) != name {
+			continue
+		}
+		prop, _ := p.Val.(ObjectProp)
+		prop.Schema = s
+		r.Properties[i].Val = prop
+	}
+	r.IsWrapped = true
+	return r, nil
+}
+
+// envelopeSchema 返回信封的schema与 $payload 字段的json名字.
+// envelope 可以是定义路径(e.g. ./internal/model.Resp), 也可以是js表达式中的go类型(e.g. model.Resp)
+func (o *OpenApi) envelopeSchema(envelope interface{}) (s *ObjectSchema, payload string, err error) {
+	var schema Schema
+	switch e := envelope.(type) {
+	case string:
+		expr, _, exist, e2 := o.getGoExprOfPath(e)
+		if e2 != nil {
+			return nil, "", e2
+		}
+		if !exist {
+			return nil, "", fmt.Errorf("can't resolve envelope '%s'", e)
+		}
+		schema, err = o.goAstToSchema(expr)
+		if err != nil {
+			return nil, "", err
+		}
+	default:
+		schema, err = o.anyToSchema(e)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	s, ok := flattenObject(schema)
+	if !ok {
+		return nil, "", fmt.Errorf("envelope '%v' must be a struct, but got %T", envelope, schema)
+	}
+	for _, p := range s.Properties {
+		prop, ok := p.Val.(ObjectProp)
+		if !ok {
+			continue
+		}
+		if v, _ := prop.Meta.Get("payload"); v == true {
+			return s, propName(p), nil
+		}
+	}
+	return nil, "", fmt.Errorf("envelope '%v' has no field with '$payload: true' meta", envelope)
+}
+
+// envelopeOfCode 返回自动包装状态码为code的响应使用的信封, 为空则不需要包装
+func (o *OpenApi) envelopeOfCode(code string) string {
+	switch {
+	case strings.HasPrefix(code, "2"):
+		if o.envelope.Auto {
+			return o.envelope.Type
+		}
+	case strings.HasPrefix(code, "4"), strings.HasPrefix(code, "5"), code == "default":
+		return o.envelope.Error
+	}
+	return ""
+}
+
+// envelopeOfCodeJs 实现 go.envelope(code) 方法, 返回 {schema, payload}, 不需要包装时返回nil
+func (o *OpenApi) envelopeOfCodeJs(code string) (interface{}, error) {
+	envelope := o.envelopeOfCode(code)
+	if envelope == "" {
+		return nil, nil
+	}
+	s, payload, err := o.envelopeSchema(envelope)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"schema":  s,
+		"payload": payload,
+	}, nil
+}
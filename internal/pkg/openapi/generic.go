@@ -0,0 +1,418 @@
+package openapi
+
+import (
+	"fmt"
+	"github.com/gopenapi/gopenapi/internal/pkg/goast"
+	"go/ast"
+	"go/parser"
+	"strings"
+	"unicode"
+)
+
+// typeArgs 是泛型类型实例化时, 类型参数到类型实参的映射
+// e.g. Page[model.Pet] 中 T => model.Pet
+type typeArgs map[string]*GoExprWithPath
+
+// newTypeArgs 将类型参数与实参一一对应, 缺少的实参会被当做任意类型
+func newTypeArgs(params []string, args []*GoExprWithPath) typeArgs {
+	ta := typeArgs{}
+	for i, p := range params {
+		if i < len(args) {
+			ta[p] = args[i]
+		} else {
+			ta[p] = &GoExprWithPath{expr: emptyInterface()}
+		}
+	}
+	return ta
+}
+
+// emptyInterface 返回 interface{} 的表达式, 即任意类型
+func emptyInterface() *ast.InterfaceType {
+	return &ast.InterfaceType{Methods: &ast.FieldList{}}
+}
+
+// instanceKey 返回泛型实例的唯一标识, 实参中有无法获取标识的类型(如匿名结构体)时返回空.
+// e.g. github.com/gopenapi/gopenapi/internal/model.Page[github.com/gopenapi/gopenapi/internal/model.Pet]
+func instanceKey(key string, argKeys []string) string {
+	if key == "" {
+		return ""
+	}
+	for _, k := range argKeys {
+		if k == "" {
+			return ""
+		}
+	}
+	return key + "[" + strings.Join(argKeys, ",") + "]"
+}
+
+// typeKey 返回类型表达式的唯一标识, 用于生成泛型实例的标识.
+// e.g.
+//   - model.Pet, 返回 xxx/model.Pet
+//   - *model.Pet, 与 model.Pet 相同, 因为它们的json格式是一样的
+//   - []model.Pet, 返回 []xxx/model.Pet
+//   - int, 返回 int
+//   - any 与 interface{}, 返回 any
+//   - 匿名结构体等没有标识的类型返回空
+func (o *OpenApi) typeKey(e *GoExprWithPath) (string, error) {
+	if e.key != "" {
+		return e.key, nil
+	}
+
+	sub := func(x ast.Expr) *GoExprWithPath {
+		return &GoExprWithPath{goparse: o.goparse, openapi: o, expr: x, file: e.file, typeArgs: e.typeArgs}
+	}
+
+	switch expr := e.expr.(type) {
+	case *ast.Ident:
+		if arg, ok := e.typeArgs[expr.Name]; ok {
+			return o.typeKey(arg)
+		}
+		if is, _ := IsBaseType(expr.Name); is || expr.Name == "any" {
+			return expr.Name, nil
+		}
+		def, exist, err := o.goparse.GetDef(o.goparse.GetPkgOfFile(e.file), expr.Name)
+		if err != nil || !exist {
+			return "", err
+		}
+		return def.TypeKey(), nil
+	case *ast.SelectorExpr:
+		def, exist, err := o.getDefOfSelector(expr, e.file)
+		if err != nil || !exist {
+			return "", err
+		}
+		return def.TypeKey(), nil
+	case *ast.StarExpr:
+		return o.typeKey(sub(expr.X))
+	case *ast.ParenExpr:
+		return o.typeKey(sub(expr.X))
+	case *ast.ArrayType:
+		k, err := o.typeKey(sub(expr.Elt))
+		if err != nil || k == "" {
+			return "", err
+		}
+		return "[]" + k, nil
+	case *ast.MapType:
+		kk, err := o.typeKey(sub(expr.Key))
+		if err != nil || kk == "" {
+			return "", err
+		}
+		vk, err := o.typeKey(sub(expr.Value))
+		if err != nil || vk == "" {
+			return "", err
+		}
+		return "map[" + kk + "]" + vk, nil
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		x, indices := unpackIndexExpr(expr)
+		k, err := o.typeKey(sub(x))
+		if err != nil {
+			return "", err
+		}
+		argKeys := make([]string, len(indices))
+		for i, idx := range indices {
+			argKeys[i], err = o.typeKey(sub(idx))
+			if err != nil {
+				return "", err
+			}
+		}
+		return instanceKey(k, argKeys), nil
+	case *ast.InterfaceType:
+		if len(expr.Methods.List) == 0 {
+			return "any", nil
+		}
+	}
+
+	return "", nil
+}
+
+// unpackIndexExpr 返回泛型实例化表达式中的类型与实参
+// e.g. Page[Pet] 返回 Page 与 [Pet]
+func unpackIndexExpr(e ast.Expr) (x ast.Expr, indices []ast.Expr) {
+	switch e := e.(type) {
+	case *ast.IndexExpr:
+		return e.X, []ast.Expr{e.Index}
+	case *ast.IndexListExpr:
+		return e.X, e.Indices
+	}
+	return nil, nil
+}
+
+// getDefOfSelector 获取 model.Pet 语法的定义, file 是表达式所在的文件
+func (o *OpenApi) getDefOfSelector(expr *ast.SelectorExpr, file string) (def *goast.Def, exist bool, err error) {
+	pkgName := expr.X.(*ast.Ident).Name
+	pkgs, err := o.goparse.GetFileImportedPkgs(file)
+	if err != nil {
+		return nil, false, err
+	}
+	pkg, isPkg := pkgs[pkgName]
+	if !isPkg {
+		return nil, false, nil
+	}
+
+	return o.goparse.GetDef(pkg.Dir, expr.Sel.Name)
+}
+
+// instantiate 处理泛型实例化的语法, e.g. Page[Pet], model.Page[model.Pet], model.Map[string, model.Pet]
+func (o *GoAstToSchema) instantiate(goExpr *GoExprWithPath) (Schema, error) {
+	x, indices := unpackIndexExpr(goExpr.expr)
+
+	var def *goast.Def
+	var exist bool
+	var err error
+	switch x := x.(type) {
+	case *ast.Ident:
+		def, exist, err = o.goparse.GetDef(o.goparse.GetPkgOfFile(goExpr.file), x.Name)
+	case *ast.SelectorExpr:
+		def, exist, err = o.openapi.getDefOfSelector(x, goExpr.file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return &ErrSchema{
+			IsSchema: true,
+			Error:    fmt.Sprintf("can't found generic Type: %s", getExprName(x)),
+		}, nil
+	}
+
+	// 实参中可能还会使用外层的类型参数, e.g. type List[T any] struct{ Page Page[T] }
+	args := make([]*GoExprWithPath, len(indices))
+	for i, idx := range indices {
+		args[i] = &GoExprWithPath{
+			goparse:  o.goparse,
+			openapi:  o.openapi,
+			expr:     idx,
+			file:     goExpr.file,
+			typeArgs: goExpr.typeArgs,
+		}
+	}
+
+	key, err := o.openapi.typeKey(goExpr)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := goExpr.doc
+	if doc == nil {
+		doc = def.Doc
+	}
+	return o.goAstToSchema(&GoExprWithPath{
+		openapi:  o.openapi,
+		goparse:  o.goparse,
+		expr:     def.Type,
+		doc:      doc,
+		file:     def.File,
+		name:     def.Name,
+		key:      key,
+		typeArgs: newTypeArgs(def.TypeParams, args),
+	})
+}
+
+// GetIndex 实现泛型实例化的js语法, e.g. model.Page[model.Pet], model.Page[int]
+func (g *GoExprWithPath) GetIndex(args ...interface{}) (interface{}, error) {
+	if len(g.typeParams) == 0 {
+		return nil, fmt.Errorf("'%s' is not a generic type", g.key)
+	}
+
+	exprs := make([]*GoExprWithPath, len(args))
+	argKeys := make([]string, len(args))
+	for i, a := range args {
+		switch a := a.(type) {
+		case *GoExprWithPath:
+			exprs[i] = a
+		case string:
+			// 基础类型, e.g. int
+			exprs[i] = &GoExprWithPath{goparse: g.goparse, openapi: g.openapi, expr: ast.NewIdent(a), file: g.file}
+		default:
+			return nil, fmt.Errorf("type argument of '%s' must be a go type, but got: %v", g.key, a)
+		}
+
+		k, err := g.openapi.typeKey(exprs[i])
+		if err != nil {
+			return nil, err
+		}
+		argKeys[i] = k
+	}
+
+	return &GoExprWithPath{
+		goparse:  g.goparse,
+		openapi:  g.openapi,
+		expr:     g.expr,
+		doc:      g.doc,
+		file:     g.file,
+		name:     g.name,
+		key:      instanceKey(g.key, argKeys),
+		typeArgs: newTypeArgs(g.typeParams, exprs),
+	}, nil
+}
+
+// splitTypeArgs 分割泛型实例化语法中的类型名与实参, 会忽略嵌套的[]
+// e.g. Page[model.Pet] 返回 Page 与 [model.Pet]
+//      Map[string, Page[model.Pet]] 返回 Map 与 [string, Page[model.Pet]]
+func splitTypeArgs(s string) (name string, args []string) {
+	i := strings.IndexByte(s, '[')
+	if i == -1 || !strings.HasSuffix(s, "]") {
+		return s, nil
+	}
+	name = s[:i]
+	inner := s[i+1 : len(s)-1]
+
+	depth, start := 0, 0
+	for j, c := range inner {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(inner[start:j]))
+				start = j + 1
+			}
+		}
+	}
+	args = append(args, strings.TrimSpace(inner[start:]))
+	return
+}
+
+// getGoExprOfPath 将定义路径转为表达式, 支持泛型实例化, 用于 x-$schema 语法.
+// e.g.
+//   ./internal/model.Pet
+//   ./internal/model.Page[./internal/model.Pet]
+//   github.com/gopenapi/gopenapi/internal/model.Page[int]
+// 实参也可以写成在泛型类型所在的文件中可用的格式, e.g. ./internal/model.Page[Pet]
+func (o *OpenApi) getGoExprOfPath(pathAndKey string) (expr *GoExprWithPath, def *goast.Def, exist bool, err error) {
+	p, k := o.goparse.SplitDefPath(pathAndKey)
+	name, argStrs := splitTypeArgs(k)
+
+	def, exist, err = o.goparse.GetDef(p, name)
+	if err != nil || !exist {
+		return
+	}
+
+	expr = &GoExprWithPath{
+		goparse: o.goparse,
+		openapi: o,
+		expr:    def.Type,
+		doc:     def.Doc,
+		file:    def.File,
+		name:    def.Name,
+		key:     def.TypeKey(),
+	}
+	if len(argStrs) == 0 {
+		return
+	}
+
+	args := make([]*GoExprWithPath, len(argStrs))
+	argKeys := make([]string, len(argStrs))
+	for i, a := range argStrs {
+		if o.isDefPath(a) {
+			var argExist bool
+			args[i], _, argExist, err = o.getGoExprOfPath(a)
+			if err != nil {
+				return
+			}
+			if !argExist {
+				err = fmt.Errorf("can't resolve type argument '%s' of '%s'", a, pathAndKey)
+				return
+			}
+		} else {
+			var x ast.Expr
+			x, err = parser.ParseExpr(a)
+			if err != nil {
+				err = fmt.Errorf("invalid type argument '%s' of '%s': %w", a, pathAndKey, err)
+				return
+			}
+			args[i] = &GoExprWithPath{goparse: o.goparse, openapi: o, expr: x, file: def.File}
+		}
+		argKeys[i], err = o.typeKey(args[i])
+		if err != nil {
+			return
+		}
+	}
+
+	expr.key = instanceKey(def.Key, argKeys)
+	expr.typeArgs = newTypeArgs(def.TypeParams, args)
+	return
+}
+
+// isDefPath 判断泛型的实参是不是定义的路径, 如 xxx/model.Pet 或者 gopkg.in/yaml.v2.MapItem.
+// 其他的实参(如 int, []int)当做go表达式解析, 带点的包路径不能当做表达式解析.
+func (o *OpenApi) isDefPath(a string) bool {
+	if _, isPath := o.goparse.FormatPath(a); isPath {
+		return true
+	}
+	return !strings.HasPrefix(a, "[]") && !strings.HasPrefix(a, "map[") && strings.Contains(a, "/")
+}
+
+// formatGoPath 和 FormatPath 一样, 不同的是还会格式化泛型的实参, 别名返回原类型的标识, 保证与 typeKey 返回的标识一致
+func (o *OpenApi) formatGoPath(pathAndKey string) (fp string, isInProject bool) {
+	fp, isInProject = o.goparse.FormatPath(pathAndKey)
+	if !isInProject {
+		return
+	}
+
+	expr, _, exist, err := o.getGoExprOfPath(pathAndKey)
+	if err != nil || !exist || expr.key == "" {
+		return
+	}
+	return expr.key, true
+}
+
+// schemaName 返回schema的名字, 泛型实例会将实参拼接到名字中, 用于生成components.
+// e.g.
+//   xxx/model.Pet => Pet
+//   xxx/model.Page[xxx/model.Pet] => PageOfPet
+//   xxx/model.Map[string,xxx/model.Pet] => MapOfStringAndPet
+//   xxx/model.Page[[]xxx/model.Pet] => PageOfPetList
+func schemaName(key string) string {
+	switch {
+	case strings.HasPrefix(key, "[]"):
+		return schemaName(key[2:]) + "List"
+	case strings.HasPrefix(key, "map["):
+		_, v := splitMapKey(key)
+		return schemaName(v) + "Map"
+	}
+
+	_, k := splitPkgPath(key)
+	if k == "" {
+		// 基础类型, e.g. int
+		k = key
+	}
+	name, args := splitTypeArgs(k)
+	name = upperFirst(name)
+	if len(args) == 0 {
+		return name
+	}
+
+	names := make([]string, len(args))
+	for i, a := range args {
+		names[i] = schemaName(a)
+	}
+	return name + "Of" + strings.Join(names, "And")
+}
+
+// splitMapKey 分割 map[K]V 格式的标识
+func splitMapKey(key string) (k, v string) {
+	depth := 0
+	for i := len("map"); i < len(key); i++ {
+		switch key[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return key[len("map["):i], key[i+1:]
+			}
+		}
+	}
+	return "", key
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -0,0 +1,58 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dop251/goja"
+	"github.com/gopenapi/gopenapi/internal/pkg/js"
+	"github.com/gopenapi/gopenapi/internal/pkg/jsonordered"
+)
+
+// helperFunc 返回调用 gopenapi.conf.js 中 helpers 的方法, 用于在注释中调用, e.g.
+//
+//   export default {
+//     helpers: {
+//       errors: function (...codes) { ... },
+//     },
+//   }
+//
+//   // $:
+//   //   response: errors(400, 404)
+//
+// 参数中的go类型会被转为与 go.parse 相同的格式, 返回值是json格式的对象.
+func (o *OpenApi) helperFunc(name string) js.Func {
+	return func(args ...interface{}) (interface{}, error) {
+		return o.callHelper(name, args)
+	}
+}
+
+// callHelper 在 gopenapi.conf.js 中运行 helpers[name](...args)
+func (o *OpenApi) callHelper(name string, args []interface{}) (interface{}, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+	argsBs, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("marshal arguments of helper '%s' err: %w", name, err)
+	}
+	nameBs, _ := json.Marshal(name)
+
+	vm, err := o.configVm()
+	if err != nil {
+		return nil, err
+	}
+	code := fmt.Sprintf(`JSON.stringify(exports.default.helpers[%s].apply(null, %s))`, nameBs, argsBs)
+	v, err := o.runLimited(vm, "helpers."+name, func() (goja.Value, error) {
+		return vm.RunScript("helpers", code)
+	})
+	if err != nil {
+		return nil, o.configError(err, "helpers."+name)
+	}
+
+	// 返回 undefined
+	s, ok := v.Export().(string)
+	if !ok {
+		return nil, nil
+	}
+	return jsonordered.UnmarshalToOrderJson([]byte(s))
+}
@@ -0,0 +1,220 @@
+package openapi
+
+import (
+	"fmt"
+	"github.com/dop251/goja"
+	"github.com/gopenapi/gopenapi/internal/pkg/jsonordered"
+	"gopkg.in/yaml.v2"
+	"strconv"
+)
+
+// gopenapi.conf.js 中可选的生命周期钩子, 它们可以直接修改参数, 也可以返回新的值:
+//   - beforeAll(doc): 在展开 x-$ 语法之前调用, 参数是源文档
+//   - onOperation(operation, {path, method, doc}): 生成文档后对每个 operation 调用
+//   - onSchema(schema, {name, doc}): 生成文档后对 components.schemas 中的每个 schema 调用
+//   - afterAll(doc): 最后调用, 参数是完整的文档
+//
+// 文档中的对象在js中是 orderedObject, 所以key的顺序不会改变. 注意钩子中新建的对象是js的普通对象,
+// 其中整数的key(如 {'404': ..., '200': ...})会按js的规则排在最前面并升序.
+const (
+	hookBeforeAll   = "beforeAll"
+	hookOnOperation = "onOperation"
+	hookOnSchema    = "onSchema"
+	hookAfterAll    = "afterAll"
+)
+
+// beforeAllScript 运行 beforeAll 钩子, 参数是文档
+const beforeAllScript = `(function (doc) {
+  var r = exports.default.beforeAll(doc);
+  return r === undefined ? doc : r;
+})`
+
+// afterHooksScript 依次运行 onOperation, onSchema 与 afterAll 钩子, 参数是文档
+const afterHooksScript = `(function (doc) {
+  var conf = exports.default;
+  var methods = ['get', 'put', 'post', 'delete', 'options', 'head', 'patch', 'trace'];
+  if (conf.onOperation && doc.paths) {
+    Object.keys(doc.paths).forEach(function (path) {
+      var item = doc.paths[path];
+      methods.forEach(function (method) {
+        if (!item || !item[method]) {
+          return;
+        }
+        var r = conf.onOperation(item[method], {path: path, method: method, doc: doc});
+        if (r !== undefined) {
+          item[method] = r;
+        }
+      });
+    });
+  }
+  if (conf.onSchema && doc.components && doc.components.schemas) {
+    var schemas = doc.components.schemas;
+    Object.keys(schemas).forEach(function (name) {
+      var r = conf.onSchema(schemas[name], {name: name, doc: doc});
+      if (r !== undefined) {
+        schemas[name] = r;
+      }
+    });
+  }
+  if (conf.afterAll) {
+    var r = conf.afterAll(doc);
+    if (r !== undefined) {
+      doc = r;
+    }
+  }
+  return doc;
+})`
+
+// runBeforeAll 运行 beforeAll 钩子, 没有定义时返回原文档
+func (o *OpenApi) runBeforeAll(kv []yaml.MapItem) ([]yaml.MapItem, error) {
+	if !o.hooks[hookBeforeAll] {
+		return kv, nil
+	}
+	return o.runHooks(beforeAllScript, kv)
+}
+
+// runAfterHooks 运行 onOperation, onSchema 与 afterAll 钩子, 都没有定义时返回原文档
+func (o *OpenApi) runAfterHooks(kv []yaml.MapItem) ([]yaml.MapItem, error) {
+	if !o.hooks[hookOnOperation] && !o.hooks[hookOnSchema] && !o.hooks[hookAfterAll] {
+		return kv, nil
+	}
+	return o.runHooks(afterHooksScript, kv)
+}
+
+func (o *OpenApi) runHooks(script string, kv []yaml.MapItem) ([]yaml.MapItem, error) {
+	vm, err := o.configVm()
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	v, err := o.runLimited(vm, "hooks", func() (goja.Value, error) {
+		f, err := vm.RunScript("hooks", script)
+		if err != nil {
+			return nil, err
+		}
+		run, _ := goja.AssertFunction(f)
+		v, err := run(goja.Undefined(), toOrderedValue(vm, yamlItemToJsonItem(kv)))
+		if err != nil {
+			return nil, err
+		}
+		// 转换时可能会运行js(如 toJSON), 所以也在js中调用
+		convert, _ := goja.AssertFunction(vm.ToValue(func(call goja.FunctionCall) goja.Value {
+			out = fromOrderedValue(vm, call.Argument(0))
+			return goja.Undefined()
+		}))
+		_, err = convert(goja.Undefined(), v)
+		return v, err
+	})
+	if err != nil {
+		return nil, o.configError(err, "hooks")
+	}
+	doc, ok := deepJsonToYaml(out).([]yaml.MapItem)
+	if !ok {
+		return nil, fmt.Errorf("hooks must return an object, but got: %v", v)
+	}
+	return doc, nil
+}
+
+// orderedObject 是保持key顺序的js对象, 实现 goja.DynamicObject.
+// js中的普通对象会将整数的key(如 responses 中的 200, 404)排在最前面, 所以传给钩子的文档中的对象都是 orderedObject.
+type orderedObject struct {
+	keys []string
+	vals map[string]goja.Value
+}
+
+func (o *orderedObject) Get(key string) goja.Value {
+	return o.vals[key]
+}
+
+func (o *orderedObject) Set(key string, val goja.Value) bool {
+	if _, ok := o.vals[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.vals[key] = val
+	return true
+}
+
+func (o *orderedObject) Has(key string) bool {
+	_, ok := o.vals[key]
+	return ok
+}
+
+func (o *orderedObject) Delete(key string) bool {
+	if _, ok := o.vals[key]; !ok {
+		return true
+	}
+	delete(o.vals, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i:i], o.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (o *orderedObject) Keys() []string {
+	return o.keys
+}
+
+// toOrderedValue 将json值转为js值, 对象转为 orderedObject
+func toOrderedValue(vm *goja.Runtime, v interface{}) goja.Value {
+	switch v := v.(type) {
+	case jsonordered.MapSlice:
+		o := &orderedObject{vals: map[string]goja.Value{}}
+		for _, item := range v {
+			o.Set(item.Key, toOrderedValue(vm, item.Val))
+		}
+		return vm.NewDynamicObject(o)
+	case []interface{}:
+		vs := make([]interface{}, len(v))
+		for i, item := range v {
+			vs[i] = toOrderedValue(vm, item)
+		}
+		return vm.NewArray(vs...)
+	case nil:
+		return goja.Null()
+	}
+	return vm.ToValue(v)
+}
+
+// fromOrderedValue 将钩子返回的js值转为有序的json值, 与 JSON.stringify 一样会忽略 undefined 与方法.
+// orderedObject 与普通对象按 Keys 的顺序, 其他对象(如 Date)使用 JSON.stringify 转换, 见 jsValueToJson.
+func fromOrderedValue(vm *goja.Runtime, v goja.Value) interface{} {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil
+	}
+	o, ok := v.(*goja.Object)
+	if !ok {
+		return v.Export()
+	}
+	if _, ok := goja.AssertFunction(o); ok {
+		return nil
+	}
+
+	switch o.ClassName() {
+	case "Array":
+		vs := make([]interface{}, o.Get("length").ToInteger())
+		for i := range vs {
+			vs[i] = fromOrderedValue(vm, o.Get(strconv.Itoa(i)))
+		}
+		return vs
+	case "Object":
+		if _, ok := o.Get("toJSON").(*goja.Object); ok {
+			return jsValueToJson(vm, o)
+		}
+		r := jsonordered.MapSlice{}
+		for _, k := range o.Keys() {
+			item := o.Get(k)
+			if item == nil || goja.IsUndefined(item) {
+				continue
+			}
+			if _, ok := goja.AssertFunction(item); ok {
+				continue
+			}
+			r = append(r, jsonordered.MapItem{Key: k, Val: fromOrderedValue(vm, item)})
+		}
+		return r
+	}
+	return jsValueToJson(vm, o)
+}
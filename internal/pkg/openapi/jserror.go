@@ -0,0 +1,142 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dop251/goja"
+	"github.com/gopenapi/gopenapi/internal/pkg/js"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ConfigError 是运行 gopenapi.conf.js 时的错误, 位置已经通过 source map 映射回了源文件, e.g.
+//
+//   run gopenapi.conf.js err: TypeError: Cannot read property 'b' of undefined
+//     key: x-$path
+//     at /app/gopenapi.conf.js:6:12
+//     yaml: paths./pet.put
+//     go: ./internal/delivery/http/handler.PetHandler.PutPet
+//
+//       5 |     let {a} = value
+//     > 6 |     return a.b.c
+//         |            ^
+//       7 |   }
+type ConfigError struct {
+	// 正在运行的 x-$ key, helper 或钩子, e.g. x-$path
+	Key string
+	// 正在处理的yaml key的路径
+	Route []string
+	// 正在处理的go定义, 即最后一次 go.parse 的参数
+	GoDef string
+
+	// 源文件中的位置, 找不到时 File 为空
+	File   string
+	Line   int
+	Column int
+	// 出错位置附近的代码
+	Frame string
+
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	var b strings.Builder
+	b.WriteString("run gopenapi.conf.js err: ")
+	b.WriteString(e.message())
+	if e.Key != "" {
+		fmt.Fprintf(&b, "\n  key: %s", e.Key)
+	}
+	if e.File != "" {
+		fmt.Fprintf(&b, "\n  at %s:%d:%d", e.File, e.Line, e.Column)
+	}
+	if len(e.Route) != 0 {
+		fmt.Fprintf(&b, "\n  yaml: %s", strings.Join(e.Route, "."))
+	}
+	if e.GoDef != "" {
+		fmt.Fprintf(&b, "\n  go: %s", e.GoDef)
+	}
+	if e.Frame != "" {
+		b.WriteString("\n\n")
+		b.WriteString(indent(e.Frame, "    "))
+	}
+	return b.String()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// message 返回不包含栈的错误信息, 栈中的位置由 File, Line, Column 表示
+func (e *ConfigError) message() string {
+	var ex *goja.Exception
+	if errors.As(e.Err, &ex) && ex.Value() != nil {
+		return ex.Value().String()
+	}
+	var ie *goja.InterruptedError
+	if errors.As(e.Err, &ie) {
+		return fmt.Sprint(ie.Value())
+	}
+	return e.Err.Error()
+}
+
+// stackFrameReg 匹配 goja.Exception 栈中的一行, e.g. "at filter (/app/gopenapi.conf.js:6:11(8))"
+var stackFrameReg = regexp.MustCompile(`at (?:.* \()?(.+):(\d+):(\d+)\(\d+\)\)?$`)
+
+// configError 将运行 gopenapi.conf.js 的错误包装为 ConfigError, 并找到栈中第一个属于配置文件的位置
+func (o *OpenApi) configError(err error, key string) error {
+	if err == nil {
+		return nil
+	}
+	var ce *ConfigError
+	if errors.As(err, &ce) {
+		return err
+	}
+
+	ce = &ConfigError{
+		Key:   key,
+		Route: append([]string(nil), o.keyRouter...),
+		GoDef: o.goDef,
+		Err:   err,
+	}
+
+	// 超时的错误也有调用栈, 出错的位置是超时时正在运行的代码
+	var stack string
+	var ex *goja.Exception
+	var ie *goja.InterruptedError
+	if errors.As(err, &ex) {
+		stack = ex.String()
+	} else if errors.As(err, &ie) {
+		stack = ie.String()
+	} else {
+		return ce
+	}
+	for _, line := range strings.Split(stack, "\n") {
+		m := stackFrameReg.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		src, ok := o.jsSources[filepath.Clean(m[1])]
+		if !ok {
+			continue
+		}
+		ce.File = m[1]
+		ce.Line, _ = strconv.Atoi(m[2])
+		ce.Column, _ = strconv.Atoi(m[3])
+		// 经过 source map 映射后的列是从0开始的
+		ce.Column++
+		ce.Frame = js.CodeFrame(src, ce.Line, ce.Column)
+		break
+	}
+
+	return ce
+}
+
+func indent(s string, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = prefix + l
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,323 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/require"
+	"github.com/gopenapi/gopenapi/internal/pkg/goast"
+	"github.com/gopenapi/gopenapi/internal/pkg/jsonordered"
+	"go/ast"
+	"go/types"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// gopenapi.conf.js 中除了 go 与 console 之外的内置模块, 出错时会抛出异常:
+//   - fs: 只读的文件系统, 相对路径基于 go.mod 所在的目录, 不能读取这个目录之外的文件.
+//     readFileSync(p), existsSync(p), readdirSync(p)
+//   - yaml: parse(s), stringify(v), 对象的key保持原有的顺序.
+//   - path: 与 node 的 path 模块相同(posix), join, dirname, basename, extname, normalize, isAbsolute, relative.
+func (o *OpenApi) registerModules(registry *require.Registry) {
+	registry.RegisterNativeModule("fs", o.fsModule)
+	registry.RegisterNativeModule("yaml", yamlModule)
+	registry.RegisterNativeModule("path", pathModule)
+}
+
+// throwErr 在js中抛出go的错误
+func throwErr(vm *goja.Runtime, err error) {
+	panic(vm.NewGoError(err))
+}
+
+// jsonToJsValue 将go的值通过json转为js的值, 保持有序对象(jsonordered.MapSlice)的顺序
+func jsonToJsValue(vm *goja.Runtime, v interface{}) goja.Value {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		throwErr(vm, err)
+	}
+	r, err := vm.RunScript("_", fmt.Sprintf("(%s)", bs))
+	if err != nil {
+		throwErr(vm, err)
+	}
+	return r
+}
+
+// jsValueToJson 将js的值转为有序的json对象
+func jsValueToJson(vm *goja.Runtime, v goja.Value) interface{} {
+	stringify, _ := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("stringify"))
+	s, err := stringify(goja.Undefined(), v)
+	if err != nil {
+		throwErr(vm, err)
+	}
+	if goja.IsUndefined(s) {
+		return nil
+	}
+	r, err := jsonordered.UnmarshalToOrderJson([]byte(s.String()))
+	if err != nil {
+		throwErr(vm, err)
+	}
+	return r
+}
+
+func (o *OpenApi) fsModule(vm *goja.Runtime, module *goja.Object) {
+	export := module.Get("exports").(*goja.Object)
+
+	export.Set("readFileSync", func(p string) string {
+		abs, err := o.fsPath(p)
+		if err != nil {
+			throwErr(vm, err)
+		}
+		bs, err := ioutil.ReadFile(abs)
+		if err != nil {
+			throwErr(vm, err)
+		}
+		return string(bs)
+	})
+	export.Set("existsSync", func(p string) bool {
+		abs, err := o.fsPath(p)
+		if err != nil {
+			throwErr(vm, err)
+		}
+		_, err = os.Stat(abs)
+		return err == nil
+	})
+	export.Set("readdirSync", func(p string) []interface{} {
+		abs, err := o.fsPath(p)
+		if err != nil {
+			throwErr(vm, err)
+		}
+		fs, err := ioutil.ReadDir(abs)
+		if err != nil {
+			throwErr(vm, err)
+		}
+		names := make([]interface{}, len(fs))
+		for i, f := range fs {
+			names[i] = f.Name()
+		}
+		return names
+	})
+}
+
+// fsPath 返回 fs 模块中路径的绝对路径, 路径(包括符号链接指向的路径)不能在 go.mod 所在的目录之外
+func (o *OpenApi) fsPath(p string) (string, error) {
+	abs := filepath.FromSlash(p)
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(o.moduleDir, abs)
+	}
+	abs = filepath.Clean(abs)
+
+	if !withinDir(o.moduleDir, abs) {
+		return "", fmt.Errorf("fs: '%s' is outside of the module root '%s'", p, o.moduleDir)
+	}
+	return abs, nil
+}
+
+func yamlModule(vm *goja.Runtime, module *goja.Object) {
+	export := module.Get("exports").(*goja.Object)
+
+	export.Set("parse", func(s string) goja.Value {
+		var v orderedYaml
+		err := yaml.Unmarshal([]byte(s), &v)
+		if err != nil {
+			throwErr(vm, err)
+		}
+		return jsonToJsValue(vm, deepYamlToJson(v.value))
+	})
+	export.Set("stringify", func(v goja.Value) string {
+		bs, err := yaml.Marshal(deepJsonToYaml(jsValueToJson(vm, v)))
+		if err != nil {
+			throwErr(vm, err)
+		}
+		return string(bs)
+	})
+}
+
+// orderedYaml 用于解析任意的yaml, 与 interface{} 不同的是, 对象会被解析为有序的 yaml.MapSlice
+type orderedYaml struct {
+	value interface{}
+}
+
+func (y *orderedYaml) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v interface{}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch v.(type) {
+	case map[interface{}]interface{}:
+		// 解析为 MapSlice 时, 其中的对象也会是 MapSlice
+		var m yaml.MapSlice
+		if err := unmarshal(&m); err != nil {
+			return err
+		}
+		y.value = m
+	case []interface{}:
+		var items []orderedYaml
+		if err := unmarshal(&items); err != nil {
+			return err
+		}
+		x := make([]interface{}, len(items))
+		for i, item := range items {
+			x[i] = item.value
+		}
+		y.value = x
+	default:
+		y.value = v
+	}
+	return nil
+}
+
+func pathModule(vm *goja.Runtime, module *goja.Object) {
+	export := module.Get("exports").(*goja.Object)
+
+	export.Set("sep", "/")
+	export.Set("join", func(elem ...string) string {
+		p := path.Join(elem...)
+		if p == "" {
+			return "."
+		}
+		return p
+	})
+	export.Set("normalize", path.Clean)
+	export.Set("dirname", path.Dir)
+	export.Set("basename", func(p string, ext string) string {
+		return strings.TrimSuffix(path.Base(p), ext)
+	})
+	export.Set("extname", path.Ext)
+	export.Set("isAbsolute", path.IsAbs)
+	export.Set("relative", func(from, to string) string {
+		rel, err := filepath.Rel(filepath.FromSlash(from), filepath.FromSlash(to))
+		if err != nil {
+			throwErr(vm, err)
+		}
+		return filepath.ToSlash(rel)
+	})
+}
+
+// jsDef 是 go.list 与 go.funcs 返回的定义
+type jsDef struct {
+	Name string `json:"name"`
+	// Key 可以直接用于 go.parse 与 x-$ 语法, e.g. github.com/gopenapi/gopenapi/internal/model.Pet
+	Key  string `json:"key"`
+	Doc  string `json:"doc"`
+	File string `json:"file"`
+	// Kind 是类型的种类: struct, interface, alias 或 type, 方法没有这个字段
+	Kind string `json:"kind,omitempty"`
+}
+
+// jsConst 是 go.consts 返回的常量
+type jsConst struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+	// Type 是常量的类型, e.g. PetStatus, 无类型的常量为空
+	Type string `json:"type"`
+	Doc  string `json:"doc"`
+}
+
+// registerGoFuncs 在 go 模块中注册查询go定义的方法, 参数是包或者类型的路径(与 go.parse 相同):
+//   - list(pkg): 包中所有的类型, 按源码顺序
+//   - enum(type): 类型的枚举值 {type, values, keys, docs}
+//   - funcs(type): 类型的所有方法, 按源码顺序
+//   - consts(pkg): 包中所有的常量, 按源码顺序
+func (o *OpenApi) registerGoFuncs(vm *goja.Runtime, export *goja.Object) {
+	export.Set("list", func(pkg string) goja.Value {
+		defs, err := o.goparse.GetTypes(pkg)
+		if err != nil {
+			throwErr(vm, err)
+		}
+		r := []jsDef{}
+		for _, d := range defs {
+			r = append(r, jsDef{
+				Name: d.Name,
+				Key:  d.Key,
+				Doc:  strings.TrimSpace(d.Doc.Text()),
+				File: d.File,
+				Kind: defKind(d),
+			})
+		}
+		return jsonToJsValue(vm, r)
+	})
+
+	export.Set("enum", func(typ string) goja.Value {
+		p, name := splitPkgPath(typ)
+		enum, err := o.goparse.GetEnum(p, name)
+		if err != nil {
+			throwErr(vm, err)
+		}
+		return jsonToJsValue(vm, enum)
+	})
+
+	export.Set("funcs", func(typ string) goja.Value {
+		p, name := splitPkgPath(typ)
+		funcs, err := o.goparse.GetFuncOfStruct(p, name)
+		if err != nil {
+			throwErr(vm, err)
+		}
+		// GetDef 会将文件转为基于gomod的引入路径
+		var defs []*goast.Def
+		for n := range funcs {
+			d, exist, err := o.goparse.GetDef(p, name+"."+n)
+			if err != nil {
+				throwErr(vm, err)
+			}
+			if exist {
+				defs = append(defs, d)
+			}
+		}
+		sort.Slice(defs, func(i, j int) bool {
+			if defs[i].File != defs[j].File {
+				return defs[i].File < defs[j].File
+			}
+			return defs[i].Line < defs[j].Line
+		})
+
+		r := []jsDef{}
+		for _, d := range defs {
+			r = append(r, jsDef{
+				Name: d.Name,
+				Key:  d.Key,
+				Doc:  strings.TrimSpace(d.Doc.Text()),
+				File: d.File,
+			})
+		}
+		return jsonToJsValue(vm, r)
+	})
+
+	export.Set("consts", func(pkg string) goja.Value {
+		consts, err := o.goparse.GetConsts(pkg)
+		if err != nil {
+			throwErr(vm, err)
+		}
+		r := []jsConst{}
+		for _, c := range consts {
+			jc := jsConst{
+				Name:  c.Name,
+				Value: c.Value,
+				Doc:   strings.TrimSpace(c.Doc.Text()),
+			}
+			if c.Type != nil {
+				jc.Type = types.ExprString(c.Type)
+			}
+			r = append(r, jc)
+		}
+		return jsonToJsValue(vm, r)
+	})
+}
+
+func defKind(d *goast.Def) string {
+	if d.IsAlias {
+		return "alias"
+	}
+	switch d.Type.(type) {
+	case *ast.StructType:
+		return "struct"
+	case *ast.InterfaceType:
+		return "interface"
+	}
+	return "type"
+}
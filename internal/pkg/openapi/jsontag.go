@@ -0,0 +1,95 @@
+package openapi
+
+import (
+	"fmt"
+	"github.com/gopenapi/gopenapi/internal/pkg/jsonordered"
+	"go/ast"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// structTag 将ast中的tag转为 reflect.StructTag, 用于读取tag的值
+func structTag(tag *ast.BasicLit) reflect.StructTag {
+	if tag == nil {
+		return ""
+	}
+	s, err := strconv.Unquote(tag.Value)
+	if err != nil {
+		return ""
+	}
+	return reflect.StructTag(s)
+}
+
+// jsonTag 是字段的json tag, 与 encoding/json 的规则一致
+type jsonTag struct {
+	// Name 是json中的字段名, 为空时使用go字段名
+	Name string
+	// Skip 表示字段不会被序列化, 即 `json:"-"`
+	Skip bool
+	// OmitEmpty 表示字段为零值时不会被序列化, 即 `json:",omitempty"`
+	OmitEmpty bool
+	// AsString 表示基础类型会被序列化为字符串, 即 `json:",string"`
+	AsString bool
+}
+
+func parseJsonTag(tag *ast.BasicLit) jsonTag {
+	t := structTag(tag).Get("json")
+	if t == "-" {
+		return jsonTag{Skip: true}
+	}
+
+	ss := strings.Split(t, ",")
+	jt := jsonTag{Name: ss[0]}
+	for _, opt := range ss[1:] {
+		switch opt {
+		case "omitempty":
+			jt.OmitEmpty = true
+		case "string":
+			jt.AsString = true
+		}
+	}
+	return jt
+}
+
+// jsonName 返回字段在json中的名字
+func (t jsonTag) jsonName(fieldName string) string {
+	if t.Name == "" {
+		return fieldName
+	}
+	return t.Name
+}
+
+// applyJsonString 处理 `json:",string"`, 只有数字与布尔类型会被序列化为字符串
+func applyJsonString(s Schema) {
+	idt, ok := s.(*IdentSchema)
+	if !ok {
+		return
+	}
+	switch idt.Type {
+	case "integer", "number", "boolean":
+		idt.Type = "string"
+		idt.Format = ""
+		// Enum 可能与原schema共享, 不能直接修改
+		enum := make([]interface{}, len(idt.Enum))
+		for i, v := range idt.Enum {
+			enum[i] = fmt.Sprint(v)
+		}
+		if idt.Enum != nil {
+			idt.Enum = enum
+		}
+		if idt.Default != nil {
+			idt.Default = fmt.Sprint(idt.Default)
+		}
+	}
+}
+
+// propName 返回对象字段在json中的名字, 字段的key是go字段名
+func propName(p jsonordered.MapItem) string {
+	if prop, ok := p.Val.(ObjectProp); ok {
+		if name := strings.Split(prop.Tag["json"], ",")[0]; name != "" {
+			return name
+		}
+	}
+	return p.Key
+}
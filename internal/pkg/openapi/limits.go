@@ -0,0 +1,150 @@
+package openapi
+
+import (
+	"fmt"
+	"github.com/dop251/goja"
+	"github.com/gopenapi/gopenapi/internal/pkg/js"
+	"time"
+)
+
+// gopenapi.conf.js 运行在沙盒中:
+//   - 没有网络: 内置模块只有 go, console, fs, yaml 与 path, 没有 http, fetch, XMLHttpRequest 等.
+//   - 不能写文件: fs 是只读的, 且只能读取 go.mod 所在目录中的文件, 见 fsPath;
+//     require 只能读取配置文件所在目录, 预设与 node_modules 中的 .js 与 .json 文件, 见 loadModuleSource.
+//   - 运行时间与调用深度有限制, 见 Limits, 防止死循环卡住CI. 注释中的js表达式也有时间限制, 见 runJsExpress.
+
+// Limits 是 gopenapi.conf.js 中的 limits 选项, 时间的单位是毫秒, 为0时不限制.
+type Limits struct {
+	// 每次调用(运行配置文件, 一个 x-$ key, helper, 钩子或注释中的js表达式)的最长时间
+	CallTimeout int `json:"callTimeout"`
+	// 一次运行中所有调用的总时间
+	TotalTimeout int `json:"totalTimeout"`
+	// 最大调用深度, 超过时抛出 RangeError
+	MaxCallDepth int `json:"maxCallDepth"`
+}
+
+// defaultLimits 在读取配置之前(运行配置文件时)使用, 也是配置中没有 limits 时的默认值
+var defaultLimits = Limits{
+	CallTimeout:  10000,
+	TotalTimeout: 120000,
+	MaxCallDepth: 1000,
+}
+
+// interruptInterval 是超时后重复中断的间隔.
+// 中断会被最内层正在运行的js消费, 如 go.parse 中运行的 helper, 而go方法可能会忽略错误继续运行, 所以需要重复中断直到调用返回.
+const interruptInterval = 10 * time.Millisecond
+
+// enableCallDepthLimit 注册配置文件中每个方法开头都会调用的 js.CallDepthFunc
+func (o *OpenApi) enableCallDepthLimit(vm *goja.Runtime) {
+	vm.Set(js.CallDepthFunc, func() {
+		js.CheckCallDepth(vm, o.limits.MaxCallDepth)
+	})
+}
+
+// limiter 返回在 runLimited 中运行注释中的js表达式(见 runJsExpress)的 js.Limiter
+func (o *OpenApi) limiter(key string) js.Limiter {
+	return func(vm *goja.Runtime, run func() (goja.Value, error)) (goja.Value, error) {
+		return o.runLimited(vm, key, run)
+	}
+}
+
+// runLimited 在 Limits 的时间内运行 fn, 超时的错误中包含正在运行的 key.
+// 嵌套的调用(如 filter 中通过 go.parse 调用 helper)由最外层的调用计时, 超时后会中断所有正在运行的js运行时.
+func (o *OpenApi) runLimited(vm *goja.Runtime, key string, fn func() (goja.Value, error)) (goja.Value, error) {
+	o.pushLimitedVm(vm)
+	defer o.popLimitedVm()
+	if o.running {
+		return fn()
+	}
+	o.running = true
+	defer func() { o.running = false }()
+	defer o.clearInterrupts()
+
+	var timeout time.Duration
+	var reason string
+	if o.limits.CallTimeout > 0 {
+		timeout = time.Duration(o.limits.CallTimeout) * time.Millisecond
+		reason = fmt.Sprintf("'%s' ran longer than %s (limits.callTimeout)", key, timeout)
+		if key == "" {
+			reason = fmt.Sprintf("gopenapi.conf.js ran longer than %s (limits.callTimeout)", timeout)
+		}
+	}
+	if o.limits.TotalTimeout > 0 {
+		total := time.Duration(o.limits.TotalTimeout) * time.Millisecond
+		remain := total - o.jsElapsed
+		if remain <= 0 {
+			return nil, fmt.Errorf("timeout: gopenapi.conf.js ran longer than %s in total (limits.totalTimeout)", total)
+		}
+		if timeout == 0 || remain < timeout {
+			timeout = remain
+			reason = fmt.Sprintf("gopenapi.conf.js ran longer than %s in total (limits.totalTimeout)", total)
+		}
+	}
+	if timeout == 0 {
+		return fn()
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		select {
+		case <-done:
+			return
+		case <-t.C:
+		}
+
+		tick := time.NewTicker(interruptInterval)
+		defer tick.Stop()
+		for {
+			o.interruptAll("timeout: " + reason)
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+
+	start := time.Now()
+	v, err := fn()
+	o.jsElapsed += time.Since(start)
+
+	close(done)
+	<-stopped
+	return v, err
+}
+
+func (o *OpenApi) pushLimitedVm(vm *goja.Runtime) {
+	o.limitedMutex.Lock()
+	defer o.limitedMutex.Unlock()
+	o.limitedVms = append(o.limitedVms, vm)
+	o.usedVms = append(o.usedVms, vm)
+}
+
+func (o *OpenApi) popLimitedVm() {
+	o.limitedMutex.Lock()
+	defer o.limitedMutex.Unlock()
+	o.limitedVms = o.limitedVms[:len(o.limitedVms)-1]
+}
+
+// interruptAll 中断所有正在运行的js运行时, 已经返回的运行时不会被中断
+func (o *OpenApi) interruptAll(reason string) {
+	o.limitedMutex.Lock()
+	defer o.limitedMutex.Unlock()
+	for _, vm := range o.limitedVms {
+		vm.Interrupt(reason)
+	}
+}
+
+// clearInterrupts 清除运行过的js运行时的中断, 嵌套的运行时可能在返回之后才被中断
+func (o *OpenApi) clearInterrupts() {
+	o.limitedMutex.Lock()
+	defer o.limitedMutex.Unlock()
+	for _, vm := range o.usedVms {
+		vm.ClearInterrupt()
+	}
+	o.usedVms = nil
+}
@@ -0,0 +1,207 @@
+package openapi
+
+import (
+	"fmt"
+	"github.com/gopenapi/gopenapi/internal/pkg/jsonordered"
+	"github.com/gopenapi/gopenapi/internal/pkg/log"
+)
+
+// modifier 修改schema的方法, 在注释中的js表达式中使用, 每次调用都会返回一个新的schema, 不会修改原schema.
+// e.g.
+//
+//	body: schema(model.Pet).omit('id').required('name')
+//
+// 参数中的字段名可以是json中的名字, 也可以是go字段名.
+type modifier func(o *ObjectSchema, args []interface{}) (*ObjectSchema, error)
+
+var modifiers = map[string]modifier{
+	// pick 只保留指定的字段
+	"pick": func(o *ObjectSchema, args []interface{}) (*ObjectSchema, error) {
+		names, err := modifierNames(o, "pick", args)
+		if err != nil {
+			return nil, err
+		}
+		return o.filterProps(func(name string) bool { return names[name] }), nil
+	},
+	// omit 删除指定的字段
+	"omit": func(o *ObjectSchema, args []interface{}) (*ObjectSchema, error) {
+		names, err := modifierNames(o, "omit", args)
+		if err != nil {
+			return nil, err
+		}
+		return o.filterProps(func(name string) bool { return !names[name] }), nil
+	},
+	// required 将指定的字段设置为必须的, 没有参数时所有字段都是必须的
+	"required": func(o *ObjectSchema, args []interface{}) (*ObjectSchema, error) {
+		names, err := modifierNames(o, "required", args)
+		if err != nil {
+			return nil, err
+		}
+		return o.setRequired(names, len(args) == 0, true), nil
+	},
+	// partial 将指定的字段设置为可选的, 没有参数时所有字段都是可选的
+	"partial": func(o *ObjectSchema, args []interface{}) (*ObjectSchema, error) {
+		names, err := modifierNames(o, "partial", args)
+		if err != nil {
+			return nil, err
+		}
+		return o.setRequired(names, len(args) == 0, false), nil
+	},
+	// extend 添加字段, 参数可以是对象或者结构体, 同名的字段会被覆盖
+	// e.g. schema(model.Pet).extend({token: 'xxx'}), schema(model.Pet).extend(model.Category)
+	"extend": func(o *ObjectSchema, args []interface{}) (*ObjectSchema, error) {
+		r := o.clone()
+		for _, a := range args {
+			openapi := o.openapi
+			if g, ok := a.(*GoExprWithPath); ok {
+				openapi = g.openapi
+			}
+			s, err := openapi.anyToSchema(a)
+			if err != nil {
+				return nil, err
+			}
+			ext, ok := flattenObject(s)
+			if !ok {
+				return nil, fmt.Errorf("the argument of extend() must be an object, but got %T", s)
+			}
+			for _, p := range ext.Properties {
+				name := propName(p)
+				r = r.filterProps(func(n string) bool { return n != name })
+				r.Properties = append(r.Properties, p)
+			}
+			r.Required = append(r.Required, ext.Required...)
+		}
+		return r, nil
+	},
+}
+
+// GetMember 实现修改schema的方法, 见 modifiers.
+func (o *ObjectSchema) GetMember(k string) (interface{}, error) {
+	return modifierFunc(o, k)
+}
+
+// GetMember 实现修改schema的方法, allOf 会被合并为一个对象后再修改.
+func (n AllOfSchema) GetMember(k string) (interface{}, error) {
+	o, _ := flattenObject(n)
+	return modifierFunc(o, k)
+}
+
+func modifierFunc(o *ObjectSchema, k string) (interface{}, error) {
+	m, ok := modifiers[k]
+	if !ok {
+		return nil, fmt.Errorf("unsupported method '%s' of schema, supported: pick, omit, required, partial, extend", k)
+	}
+	return func(args ...interface{}) (interface{}, error) {
+		return m(o, args)
+	}, nil
+}
+
+// flattenObject 将schema转为对象, allOf 会合并所有成员的字段.
+func flattenObject(s Schema) (*ObjectSchema, bool) {
+	switch s := s.(type) {
+	case *ObjectSchema:
+		return s, true
+	case AllOfSchema:
+		o := &ObjectSchema{
+			Type:       "object",
+			Properties: s.Properties,
+			IsSchema:   true,
+		}
+		for _, item := range s.AllOf {
+			if obj, ok := flattenObject(item); ok {
+				o.Required = append(o.Required, obj.Required...)
+				if o.openapi == nil {
+					o.openapi = obj.openapi
+				}
+			}
+		}
+		return o, true
+	}
+	return nil, false
+}
+
+// modifierNames 读取修改方法的参数, 参数必须是字段名
+func modifierNames(o *ObjectSchema, method string, args []interface{}) (map[string]bool, error) {
+	names := map[string]bool{}
+	for _, a := range args {
+		name, ok := a.(string)
+		if !ok {
+			return nil, fmt.Errorf("the arguments of %s() must be field names, but got %v", method, a)
+		}
+		names[name] = true
+	}
+
+	for name := range names {
+		exist := false
+		for _, p := range o.Properties {
+			if propName(p) == name || p.Key == name {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			log.Warningf("%s(): field '%s' is not found in schema", method, name)
+		}
+	}
+
+	// 统一使用json中的名字
+	for _, p := range o.Properties {
+		if names[p.Key] {
+			names[propName(p)] = true
+		}
+	}
+	return names, nil
+}
+
+// clone 返回新的schema, 修改它的字段不会影响原schema.
+// 修改后的schema与原schema不同, 所以没有$ref.
+func (o *ObjectSchema) clone() *ObjectSchema {
+	r := *o
+	r.Ref = ""
+	r.Properties = append(jsonordered.MapSlice(nil), o.Properties...)
+	r.Required = append([]string(nil), o.Required...)
+	return &r
+}
+
+// filterProps 返回只有keep为true的字段的schema, keep的参数是json中的名字
+func (o *ObjectSchema) filterProps(keep func(name string) bool) *ObjectSchema {
+	r := o.clone()
+	r.Properties = nil
+	for _, p := range o.Properties {
+		if keep(propName(p)) {
+			r.Properties = append(r.Properties, p)
+		}
+	}
+	r.Required = nil
+	for _, name := range o.Required {
+		if keep(name) {
+			r.Required = append(r.Required, name)
+		}
+	}
+	return r
+}
+
+// setRequired 设置字段是否是必须的, names 是json中的名字, all 为true时设置所有字段
+func (o *ObjectSchema) setRequired(names map[string]bool, all bool, required bool) *ObjectSchema {
+	r := o.clone()
+	current := map[string]bool{}
+	for _, name := range o.Required {
+		current[name] = true
+	}
+
+	r.Required = nil
+	for i, p := range r.Properties {
+		name := propName(p)
+		if all || names[name] {
+			current[name] = required
+			if prop, ok := p.Val.(ObjectProp); ok {
+				prop.Required = required
+				r.Properties[i].Val = prop
+			}
+		}
+		if current[name] {
+			r.Required = append(r.Required, name)
+		}
+	}
+	return r
+}
@@ -0,0 +1,130 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dop251/goja_nodejs/require"
+	"github.com/gopenapi/gopenapi/internal/pkg/js"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gopenapi.conf.js 可以引入本地的文件, 用于在多个项目中共享配置:
+//   - 相对路径, 如 import {processSchema} from './conf/schema', 相对于引入它的文件所在的目录.
+//   - 预设(preset), 如 import company from 'company', 会依次在以下目录中查找 company 目录(index.js 或 package.json 中的 main):
+//     - 配置文件所在目录下的 .gopenapi/presets
+//     - ~/.gopenapi/presets
+//     - 配置文件所在目录及上级目录中的 node_modules
+// 引入的 .js 文件与 gopenapi.conf.js 一样会被babel转换为ES5, 错误的位置也会被映射回源文件.
+// 只能引入以上目录中的文件, 见 requireRoots.
+const presetsDir = ".gopenapi/presets"
+
+// presetDirs 返回查找预设的目录
+func presetDirs(jsFile string) []string {
+	dirs := []string{filepath.Join(filepath.Dir(jsFile), presetsDir)}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, presetsDir))
+	}
+	for i, d := range dirs {
+		dirs[i] = filepath.ToSlash(d)
+	}
+	return dirs
+}
+
+// requireRoots 返回 require 可以读取的目录: 配置文件所在的目录, 预设的目录, 与配置文件所在目录的上级目录中的 node_modules
+func (o *OpenApi) requireRoots() []string {
+	dir := filepath.Dir(o.jsFile)
+	roots := []string{dir}
+	for _, d := range presetDirs(o.jsFile) {
+		roots = append(roots, filepath.FromSlash(d))
+	}
+	for d := filepath.Dir(dir); ; d = filepath.Dir(d) {
+		roots = append(roots, filepath.Join(d, "node_modules"))
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	return roots
+}
+
+// requirePath 检查 require 的文件, 路径(包括符号链接指向的路径)不能在 requireRoots 之外.
+// 之外的文件不存在时当做不存在, 这样 require 会继续在其他目录中查找, 存在时返回错误.
+func (o *OpenApi) requirePath(p string) error {
+	abs, err := filepath.Abs(filepath.FromSlash(p))
+	if err != nil {
+		return err
+	}
+	for _, root := range o.requireRoots() {
+		if withinDir(root, abs) {
+			return nil
+		}
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return require.ModuleFileDoesNotExistError
+	}
+	return fmt.Errorf("require: '%s' is outside of the config directory, presets and node_modules", p)
+}
+
+// withinDir 返回 p 是否在 root 目录中, 会使用符号链接指向的路径
+func withinDir(root, p string) bool {
+	real := filepath.Clean(p)
+	if r, err := filepath.EvalSymlinks(root); err == nil {
+		root = r
+	}
+	if r, err := filepath.EvalSymlinks(real); err == nil {
+		real = r
+	}
+	rel, err := filepath.Rel(root, real)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// newRegistry 创建 require 的注册表, 用于加载内置模块与本地文件
+func (o *OpenApi) newRegistry() *require.Registry {
+	return require.NewRegistry(
+		require.WithLoader(o.loadModuleSource),
+		require.WithGlobalFolders(presetDirs(o.jsFile)...),
+	)
+}
+
+// loadModuleSource 读取 require 的文件, .js 文件会被babel转换为ES5, 并在末尾加上 source map 的地址.
+// goja 读取 source map 时也会调用它, 这时返回转换时生成的 source map.
+func (o *OpenApi) loadModuleSource(p string) ([]byte, error) {
+	if m, ok := o.jsMaps[p]; ok {
+		return m, nil
+	}
+
+	// 只能引入 .js 与 .json 文件, 其他文件当做不存在, require 会继续尝试 p.js, p.json 与 p/index.js
+	ext := path.Ext(p)
+	if ext != ".js" && ext != ".json" {
+		return nil, require.ModuleFileDoesNotExistError
+	}
+	if err := o.requirePath(p); err != nil {
+		return nil, err
+	}
+	bs, err := require.DefaultSourceLoader(p)
+	if err != nil {
+		return nil, err
+	}
+	if ext != ".js" {
+		return bs, nil
+	}
+
+	code, srcMap, err := js.TransformCached(string(bs), p, js.DefaultCacheDir())
+	if err != nil {
+		return nil, fmt.Errorf("transform '%s' to ES5 err: %w", p, err)
+	}
+	o.jsSources[filepath.Clean(p)] = string(bs)
+
+	if srcMap != nil && srcMap.Mappings != "" {
+		mapBs, err := json.Marshal(srcMap)
+		if err != nil {
+			return nil, err
+		}
+		o.jsMaps[p+".map"] = mapBs
+		code = strings.TrimRight(code, "\n") + "\n//# sourceMappingURL=" + path.Base(p) + ".map"
+	}
+
+	return []byte(code), nil
+}
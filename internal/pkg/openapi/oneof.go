@@ -0,0 +1,200 @@
+package openapi
+
+import (
+	"fmt"
+	"github.com/gopenapi/gopenapi/internal/pkg/jsonordered"
+	"github.com/gopenapi/gopenapi/internal/pkg/log"
+	"go/ast"
+	"strings"
+)
+
+// interfaceToOneOf 将接口转为oneOf, 成员来自接口注释中的meta, 或者包中实现了接口的类型.
+// 支持的注释如下:
+//
+//   // Shape 是图形
+//   // $oneOf: [Circle, Square]
+//   // $discriminator: kind
+//   type Shape interface { Area() float64 }
+//
+// 或者指定mapping:
+//
+//   // $discriminator:
+//   //   propertyName: kind
+//   //   mapping:
+//   //     circle: Circle
+//   //     square: Square
+//
+// 没有$oneOf时会查找包中实现了接口所有方法的类型, 空接口(interface{})则返回nil.
+func (o *GoAstToSchema) interfaceToOneOf(goExpr *GoExprWithPath, expr *ast.InterfaceType) (*OneOfSchema, error) {
+	// 接口定义上的注释, goExpr.doc 可能是字段上的注释
+	doc := goExpr.doc
+	pkgDir := o.goparse.GetPkgOfFile(goExpr.file)
+	if goExpr.key != "" {
+		p, member := splitPkgPath(goExpr.key)
+		def, exist, err := o.goparse.GetDef(p, member)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			doc = def.Doc
+			pkgDir = p
+		}
+	}
+	gd, err := o.openapi.parseGoDoc(doc.Text(), goExpr.file)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []*GoExprWithPath
+	if v, ok := gd.Meta.Get("oneOf"); ok {
+		vs, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("$oneOf of '%s' must be an array, but %T", goExpr.key, v)
+		}
+		for _, v := range vs {
+			m, err := o.metaToGoExpr(v, goExpr.file)
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, m)
+		}
+	} else {
+		impls, err := o.goparse.GetImplementers(pkgDir, expr)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range impls {
+			members = append(members, &GoExprWithPath{
+				goparse: o.goparse,
+				openapi: o.openapi,
+				expr:    d.Type,
+				doc:     d.Doc,
+				file:    d.File,
+				name:    d.Name,
+				key:     d.Key,
+			})
+		}
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	schema := &OneOfSchema{IsSchema: true}
+	refs := map[string]string{}
+	for _, m := range members {
+		s, err := o.goAstToSchema(m)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			continue
+		}
+		schema.OneOf = append(schema.OneOf, s)
+		if ref := schemaRef(s); ref != "" {
+			refs[m.key] = ref
+		}
+	}
+
+	d, ok := gd.Meta.Get("discriminator")
+	if !ok {
+		return schema, nil
+	}
+	switch d := d.(type) {
+	case string:
+		// 根据字段的值生成mapping, 字段只有一个枚举值时使用它(e.g. `binding:"oneof=circle"`), 否则使用类型名.
+		schema.Discriminator = &Discriminator{PropertyName: d}
+		for i, s := range schema.OneOf {
+			ref := schemaRef(s)
+			if ref == "" {
+				continue
+			}
+			value := ref[strings.LastIndexByte(ref, '/')+1:]
+			if v, ok := discriminatorValue(schema.OneOf[i], d); ok {
+				value = v
+			}
+			schema.Discriminator.Mapping = append(schema.Discriminator.Mapping, jsonordered.MapItem{Key: value, Val: ref})
+		}
+	case jsonordered.MapSlice:
+		schema.Discriminator = &Discriminator{}
+		if p, ok := d.Get("propertyName"); ok {
+			schema.Discriminator.PropertyName = fmt.Sprint(p)
+		}
+		if mapping, ok := d.Get("mapping"); ok {
+			mapping, ok := mapping.(jsonordered.MapSlice)
+			if !ok {
+				return nil, fmt.Errorf("$discriminator.mapping of '%s' must be an object, but %T", goExpr.key, mapping)
+			}
+			for _, item := range mapping {
+				m, err := o.metaToGoExpr(item.Val, goExpr.file)
+				if err != nil {
+					return nil, err
+				}
+				ref, ok := refs[m.key]
+				if !ok {
+					log.Warningf("discriminator mapping '%s' of '%s' is not a member of oneOf or is not defined in components", item.Key, goExpr.key)
+					continue
+				}
+				schema.Discriminator.Mapping = append(schema.Discriminator.Mapping, jsonordered.MapItem{Key: item.Key, Val: ref})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("$discriminator of '%s' must be a string or an object, but %T", goExpr.key, d)
+	}
+
+	return schema, nil
+}
+
+// metaToGoExpr 将meta中的值转为go表达式, 值可以是已经运行过的js表达式, 也可以是字符串, e.g. model.Circle
+func (o *GoAstToSchema) metaToGoExpr(v interface{}, file string) (*GoExprWithPath, error) {
+	if s, ok := v.(string); ok {
+		r, err := o.openapi.runJsExpress(s, file)
+		if err != nil {
+			return nil, err
+		}
+		v = r
+	}
+	g, ok := v.(*GoExprWithPath)
+	if !ok {
+		return nil, fmt.Errorf("'%v' is not a go type", v)
+	}
+	return g, nil
+}
+
+// schemaRef 返回schema的$ref, 没有定义在components中的schema返回空
+func schemaRef(s Schema) string {
+	switch s := s.(type) {
+	case *ObjectSchema:
+		return s.Ref
+	case *ArraySchema:
+		return s.Ref
+	case *MapSchema:
+		return s.Ref
+	case *IdentSchema:
+		return s.Ref
+	case *OneOfSchema:
+		return s.Ref
+	case *RefSchema:
+		return s.Ref
+	case *RawSchema:
+		return s.Ref
+	}
+	return ""
+}
+
+// discriminatorValue 返回结构体中区分字段的唯一枚举值
+func discriminatorValue(s Schema, propertyName string) (string, bool) {
+	obj, ok := s.(*ObjectSchema)
+	if !ok {
+		return "", false
+	}
+	for _, p := range obj.Properties {
+		prop, ok := p.Val.(ObjectProp)
+		if !ok || propName(p) != propertyName {
+			continue
+		}
+		if idt, ok := prop.Schema.(*IdentSchema); ok && len(idt.Enum) == 1 {
+			return fmt.Sprint(idt.Enum[0]), true
+		}
+	}
+	return "", false
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"github.com/dop251/goja"
 	"github.com/dop251/goja_nodejs/console"
-	"github.com/dop251/goja_nodejs/require"
 	"github.com/gopenapi/gopenapi/internal/pkg/goast"
 	"github.com/gopenapi/gopenapi/internal/pkg/gosrc"
 	"github.com/gopenapi/gopenapi/internal/pkg/js"
@@ -15,22 +14,73 @@ import (
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	logn "log"
+	"math"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type OpenApi struct {
 	goparse *goast.GoParse
-
-	// js config
-	jsConfig string
+	// go.mod 所在的目录, 是 fs 模块能读取的根目录
+	moduleDir string
+
+	// js config, 编译后的 gopenapi.conf.js
+	jsConfig *goja.Program
+	// 运行 gopenapi.conf.js 的js运行时, 每次运行只初始化一次, 见 configVm
+	vm *goja.Runtime
+	// vm 中 go 模块导出的对象
+	goModule *goja.Object
+	// 当前正在处理的yaml key的路径与go定义, 用于错误提示, 见 ConfigError
+	keyRouter []string
+	goDef     string
+	// gopenapi.conf.js 的绝对路径
+	jsFile string
+	// gopenapi.conf.js 与它引入的文件的源码, 用于在错误中显示代码. 绝对路径 => 源码
+	jsSources map[string]string
+	// 引入的文件的 source map, 见 loadModuleSource. 路径 => json
+	jsMaps map[string][]byte
+	// 运行 gopenapi.conf.js 的限制, 见 runLimited
+	limits Limits
+	// 是否正在运行 gopenapi.conf.js, 与已经运行的总时间
+	running   bool
+	jsElapsed time.Duration
+	// 正在运行的js运行时, 超时后都需要中断. 注释中的js表达式与配置运行在不同的运行时中, 且可能互相嵌套, 见 runLimited
+	limitedVms []*goja.Runtime
+	// 最外层的调用中运行过的js运行时, 调用结束后清除它们的中断
+	usedVms      []*goja.Runtime
+	limitedMutex sync.Mutex
 
 	// schemas 存放需要refs的schemas
 	// key is the def key in go (e.g. components/schema/Pet)
 	schemas    map[string]Schema
 	schemasDef map[string]string
+
+	// go类型到schema的映射, 包含内置的与gopenapi.conf.js中配置的
+	typeMapping TypeMapping
+	// 是否将json tag中没有omitempty的字段当做必须的字段
+	jsonRequired bool
+	// 是否为含有只读/只写字段的component生成 XRead 与 XWrite
+	readWriteVariants bool
+	// 响应的信封, 见 EnvelopeConfig
+	envelope EnvelopeConfig
+	// gopenapi.conf.js 中 helpers 的方法名
+	helpers map[string]bool
+	// gopenapi.conf.js 中定义了的生命周期钩子, 见 hooks.go
+	hooks map[string]bool
+	// 用go实现的 x-$ 语法, 见 RegisterDirective. 不带 x-$ 的名字 => Directive
+	directives map[string]Directive
+	// 正在处理的openapi文档的版本, 如 3.0.1
+	version string
+
+	// autoComponents 是 x-$autoComponents 指定的命名方式, 为空时不自动注册components
+	autoComponents string
+	// 自动注册的components, 按注册顺序. go def path => schema 名字
+	autoSchemas []autoSchema
 }
 
 func NewOpenApi(gomodFile string, jsFile string) (*OpenApi, error) {
@@ -45,18 +95,41 @@ func NewOpenApi(gomodFile string, jsFile string) (*OpenApi, error) {
 		return nil, fmt.Errorf("load js config err: %w", err)
 	}
 	jsConfig := string(bs)
+	jsFile, err = filepath.Abs(jsFile)
+	if err != nil {
+		return nil, err
+	}
 
-	newCode, _, err := js.Transform(jsConfig, jsFile)
+	// babel的结果会缓存在磁盘上, 配置没有修改时不需要再次转换
+	newCode, srcMap, err := js.TransformCached(jsConfig, jsFile, js.DefaultCacheDir())
 	if err != nil {
 		return nil, fmt.Errorf("transform js config to ES5 err: %w", err)
 	}
 
-	return &OpenApi{
+	// 使用 source map, 让错误中的位置是源文件中的位置
+	program, err := js.Compile(jsFile, newCode, srcMap)
+	if err != nil {
+		return nil, fmt.Errorf("compile js config err: %w", err)
+	}
+
+	o := &OpenApi{
 		goparse:    p,
-		jsConfig:   newCode,
+		moduleDir:  goSrc.AbsModuleFileDir,
+		jsConfig:   program,
+		jsFile:     jsFile,
+		jsSources:  map[string]string{jsFile: jsConfig},
+		jsMaps:     map[string][]byte{},
+		limits:     defaultLimits,
 		schemas:    map[string]Schema{},
 		schemasDef: map[string]string{},
-	}, nil
+	}
+
+	err = o.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
 }
 
 // PkgGetter 实现了 GetMember 接口, 用来给js解析器执行 member 语法.
@@ -75,22 +148,22 @@ func (p PkgGetter) GetMember(k string) (interface{}, error) {
 		return nil, err
 	}
 
-	var expr interface{}
 	if !exist {
-		expr = &NotFoundGoExpr{
+		return &NotFoundGoExpr{
 			key: k,
 			pkg: p.pkg.Dir,
-		}
+		}, nil
 	}
 
-	expr = &GoExprWithPath{
-		goparse: p.goparse,
-		openapi: p.openApi,
-		expr:    def.Type,
-		doc:     def.Doc,
-		file:    def.File,
-		name:    def.Name,
-		key:     def.Key,
+	expr := &GoExprWithPath{
+		goparse:    p.goparse,
+		openapi:    p.openApi,
+		expr:       def.Type,
+		doc:        def.Doc,
+		file:       def.File,
+		name:       def.Name,
+		key:        def.Key,
+		typeParams: def.TypeParams,
 	}
 
 	return expr, nil
@@ -110,13 +183,30 @@ func (o *OpenApi) runJsExpress(code string, goFilePath string) (interface{}, err
 	v, err := js.RunJs(code, func(name string) (interface{}, error) {
 		// builtin function:
 		// - schema: for schemas of openapi
+		// - wrap: wraps the response by envelope, see EnvelopeConfig
 		switch name {
 		case "schema":
 			return func(args ...interface{}) (interface{}, error) {
 				stru := args[0]
 				return o.anyToSchema(stru)
 			}, nil
+		case "wrap":
+			return func(args ...interface{}) (interface{}, error) {
+				if len(args) == 0 {
+					return nil, fmt.Errorf("wrap() requires the payload argument")
+				}
+				var envelope interface{}
+				if len(args) > 1 {
+					envelope = args[1]
+				}
+				return o.wrapSchema(args[0], envelope)
+			}, nil
 		default:
+			// gopenapi.conf.js 中的 helpers, 优先于包名, 否则 errors() 会被当做 errors 包
+			if o.helpers[name] {
+				return o.helperFunc(name), nil
+			}
+
 			// 获取当前文件所有引入的包
 			pkgs, err := o.goparse.GetFileImportedPkgs(goFilePath)
 			if err != nil {
@@ -142,44 +232,74 @@ func (o *OpenApi) runJsExpress(code string, goFilePath string) (interface{}, err
 
 			if exist {
 				expr := &GoExprWithPath{
-					goparse: o.goparse,
-					openapi: o,
-					expr:    def.Type,
-					doc:     def.Doc,
-					file:    def.File,
-					name:    def.Name,
-					key:     def.Key,
+					goparse:    o.goparse,
+					openapi:    o,
+					expr:       def.Type,
+					doc:        def.Doc,
+					file:       def.File,
+					name:       def.Name,
+					key:        def.Key,
+					typeParams: def.TypeParams,
 				}
 				return expr, nil
 			}
 		}
 
 		return nil, nil
-	})
+	}, o.limiter(code))
 	if err != nil {
 		return nil, err
 	}
 	return v, nil
 }
 
+// baseType 是go基础类型对应的openapi类型
+type baseType struct {
+	Type   string
+	Format string
+	// 取值范围, 只有整数类型有
+	Minimum *float64
+	Maximum *float64
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+// go基础类型 => openapi类型
+// 不能用format表示范围的整数类型, 需要明确的指定最大值与最小值
+var baseTypes = map[string]baseType{
+	"bool":    {Type: "boolean"},
+	"string":  {Type: "string"},
+	"float32": {Type: "number", Format: "float"},
+	"float64": {Type: "number", Format: "double"},
+	"int":     {Type: "integer", Format: "int64"},
+	"int64":   {Type: "integer", Format: "int64"},
+	"int32":   {Type: "integer", Format: "int32"},
+	"rune":    {Type: "integer", Format: "int32"},
+	"int16":   {Type: "integer", Format: "int32", Minimum: floatPtr(math.MinInt16), Maximum: floatPtr(math.MaxInt16)},
+	"int8":    {Type: "integer", Format: "int32", Minimum: floatPtr(math.MinInt8), Maximum: floatPtr(math.MaxInt8)},
+	"uint":    {Type: "integer", Format: "int64", Minimum: floatPtr(0)},
+	"uint64":  {Type: "integer", Format: "int64", Minimum: floatPtr(0)},
+	"uintptr": {Type: "integer", Format: "int64", Minimum: floatPtr(0)},
+	"uint32":  {Type: "integer", Format: "int64", Minimum: floatPtr(0), Maximum: floatPtr(math.MaxUint32)},
+	"uint16":  {Type: "integer", Format: "int32", Minimum: floatPtr(0), Maximum: floatPtr(math.MaxUint16)},
+	"uint8":   {Type: "integer", Format: "int32", Minimum: floatPtr(0), Maximum: floatPtr(math.MaxUint8)},
+	"byte":    {Type: "integer", Format: "int32", Minimum: floatPtr(0), Maximum: floatPtr(math.MaxUint8)},
+}
+
 // all type of openapi: array, boolean, integer, number , object, string
 func IsBaseType(t string) (is bool, openApiType string) {
-	switch t {
-	case "int64", "int32", "int8", "int", "uint8", "uint32", "uint64", "uint":
-		return true, "integer"
-	case "bool":
-		return true, "boolean"
-	case "float32", "float64":
-		return true, "number"
-	case "byte":
-		return true, "integer"
-	case "string":
-		return true, "string"
-	case "complex128":
-		// 复数 暂不处理
-		return true, "complex"
+	bt, ok := baseTypes[t]
+	if !ok {
+		return
 	}
-	return
+	return true, bt.Type
+}
+
+// isComplexType 判断是否是复数, 复数不能被json序列化
+func isComplexType(t string) bool {
+	return t == "complex64" || t == "complex128"
 }
 
 func yamlItemToJsonItem(i []yaml.MapItem) jsonordered.MapSlice {
@@ -293,7 +413,7 @@ func yamlKeyToString(key interface{}) string {
 // 满足以下条件:
 // - 以{}或[]包裹的字符串
 // - model.X 格式
-// - 函数调用: 目前只有schema()
+// - 函数调用: schema(), wrap() 与 gopenapi.conf.js 中的 helpers
 func (o *OpenApi) guessIsJs(s string, filePath string) bool {
 	//return false
 	if len(s) < 2 {
@@ -305,8 +425,8 @@ func (o *OpenApi) guessIsJs(s string, filePath string) bool {
 	if s[0] == '[' && s[len(s)-1] == ']' {
 		return true
 	}
-	// 匹配model.Pet 和 Pet
-	reg := regexp.MustCompile(`^\w+(\.\w+)?$`)
+	// 匹配model.Pet 和 Pet, 以及泛型 model.Page[model.Pet]
+	reg := regexp.MustCompile(`^\w+(\.\w+)?(\[[\w.,\s\[\]]+\])?$`)
 	if reg.MatchString(s) {
 		// 由于字母格式太常见, 所以还需要再次校验, 只有在go中定义了的结构体才能被当做js
 		//o.getGoStruct()
@@ -317,8 +437,11 @@ func (o *OpenApi) guessIsJs(s string, filePath string) bool {
 		return v != nil
 	}
 
-	if strings.HasPrefix(s, "schema(") && strings.HasSuffix(s, ")") {
-		return true
+	if i := strings.IndexByte(s, '('); i != -1 && strings.HasSuffix(s, ")") {
+		f := s[:i]
+		if f == "schema" || f == "wrap" || o.helpers[f] {
+			return true
+		}
 	}
 	return false
 }
@@ -410,9 +533,7 @@ func (o *OpenApi) fullCommentMeta(i []yaml.MapItem, filename string) ([]yaml.Map
 // 入口
 // pathAndKey: e.g. github.com/gopenapi/gopenapi/internal/model.Tag
 func (o *OpenApi) getGoStruct(pathAndKey string) (g *GoStruct, exist bool, err error) {
-	p, k := splitPkgPath(pathAndKey)
-
-	def, exist, err := o.goparse.GetDef(p, k)
+	goExpr, def, exist, err := o.getGoExprOfPath(pathAndKey)
 	if err != nil {
 		err = fmt.Errorf("GetDef error: %w", err)
 		return
@@ -427,10 +548,10 @@ func (o *OpenApi) getGoStruct(pathAndKey string) (g *GoStruct, exist bool, err e
 		return
 	}
 
-	// 如果是一个结构体, 则自动转为schema
+	// 如果是一个结构体或接口, 则自动转为schema
 	// 用于在 x-$schema 语法中使用
 	switch def.Type.(type) {
-	case *ast.StructType:
+	case *ast.StructType, *ast.InterfaceType:
 		expr := &GoExprWithPath{
 			goparse: o.goparse,
 			openapi: o,
@@ -438,7 +559,8 @@ func (o *OpenApi) getGoStruct(pathAndKey string) (g *GoStruct, exist bool, err e
 			doc:     def.Doc,
 			file:    def.File,
 			//name:    def.Name,
-			key: def.Key,
+			key:      goExpr.key,
+			typeArgs: goExpr.typeArgs,
 		}
 		g.Schema, err = o.goAstToSchema(expr)
 		if err != nil {
@@ -451,17 +573,93 @@ func (o *OpenApi) getGoStruct(pathAndKey string) (g *GoStruct, exist bool, err e
 	return
 }
 
+// Route 是在go注释中通过 $route 声明的路由, 用于 x-$paths 语法.
+// e.g.
+//   // $route: GET /pet/{id}
+type Route struct {
+	// Method 是小写的http方法, 如 get
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Key 是方法的定义路径, 可以直接用于 x-$path 语法.
+	// e.g. github.com/gopenapi/gopenapi/internal/delivery/http/handler.PetHandler.GetPet
+	Key string `json:"key"`
+}
+
+// getRoutes 扫描包中所有的函数与方法, 返回注释中声明了 $route 的路由.
+// pkgPath: e.g. ./internal/delivery/http/handler
+func (o *OpenApi) getRoutes(pkgPath string) (routes []Route, err error) {
+	pkgPath, inProject := o.goparse.FormatPath(pkgPath)
+	if !inProject {
+		err = fmt.Errorf("can't resolve package path: '%s'", pkgPath)
+		return
+	}
+
+	funcs, err := o.goparse.GetFuncs(pkgPath)
+	if err != nil {
+		err = fmt.Errorf("GetFuncs error: %w", err)
+		return
+	}
+
+	for _, f := range funcs {
+		doc := f.Doc.Text()
+		// 只有包含route的注释才需要解析, 避免执行无用的js表达式
+		if !strings.Contains(doc, "route") {
+			continue
+		}
+
+		g, err := o.parseGoDoc(doc, f.File)
+		if err != nil {
+			return nil, fmt.Errorf("parseGoDoc error: %w", err)
+		}
+
+		r, exist := g.Meta.Get("route")
+		if !exist {
+			continue
+		}
+		rs, _ := r.(string)
+		ss := strings.Fields(rs)
+		if len(ss) != 2 {
+			log.Warningf("error at %s : invalid $route '%v', it should be like 'GET /pet/{id}'", f.Key, r)
+			continue
+		}
+
+		routes = append(routes, Route{
+			Method: strings.ToLower(ss[0]),
+			Path:   ss[1],
+			Key:    f.Key,
+		})
+	}
+
+	return
+}
+
 // splitPkgPath 分割路径src 为path和包名
 //  pathAndKey: ../internal/pkg/goast.GoMeta
 //  output:
 //    path: ../internal/pkg/goast
 //    key: GoMeta
+// 泛型实例化的实参会保留在key中:
+//  pathAndKey: ./internal/model.Page[./internal/model.Pet]
+//  output:
+//    path: ./internal/model
+//    key: Page[./internal/model.Pet]
+// 包路径的最后一级也可能含有".", 如 gopkg.in/yaml.v2.MapItem, 所以在最后一个"."处分割, 只适用于类型的标识.
+// 可能是方法的路径(e.g. handler.PetHandler.GetPet)使用 GoParse.SplitDefPath.
 func splitPkgPath(src string) (pa, member string) {
+	typeArgs := ""
+	if i := strings.IndexByte(src, '['); i != -1 {
+		src, typeArgs = src[:i], src[i:]
+	}
+
 	p1, filename := path.Split(src)
-	ss := strings.Split(filename, ".")
-	if len(ss) != 0 {
-		p1 += ss[0]
-		member = strings.Join(ss[1:], ".")
+	if i := strings.LastIndexByte(filename, '.'); i != -1 {
+		p1 += filename[:i]
+		member = filename[i+1:]
+	} else {
+		p1 += filename
+	}
+	if member != "" {
+		member += typeArgs
 	}
 
 	pa = p1
@@ -544,20 +742,56 @@ func (p ParamsList) ToYaml(useTag string) interface{} {
 	return r
 }
 
-// 分割tag
+// 分割tag, 与 reflect.StructTag 的规则一致, 所以tag的值中可以包含空格与冒号
+// e.g. `json:"name" validate:"oneof=a b c"` 返回 {json: name, validate: oneof=a b c}
 func encodeTag(tag *ast.BasicLit) map[string]string {
 	if tag == nil {
 		return nil
 	}
 
-	tags := strings.Trim(tag.Value, "`")
+	tags := string(structTag(tag))
 	r := map[string]string{}
 
-	for _, t := range strings.Split(tags, " ") {
-		ss := strings.Split(t, ":")
-		if len(ss) == 2 {
-			r[ss[0]] = strings.Trim(ss[1], `"`)
+	for tags != "" {
+		// 跳过空格
+		i := 0
+		for i < len(tags) && tags[i] == ' ' {
+			i++
+		}
+		tags = tags[i:]
+		if tags == "" {
+			break
+		}
+
+		// key 是到冒号为止的非控制字符
+		i = 0
+		for i < len(tags) && tags[i] > ' ' && tags[i] != ':' && tags[i] != '"' && tags[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tags) || tags[i] != ':' || tags[i+1] != '"' {
+			break
+		}
+		name := tags[:i]
+		tags = tags[i+1:]
+
+		// value 是带引号的字符串
+		i = 1
+		for i < len(tags) && tags[i] != '"' {
+			if tags[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tags) {
+			break
 		}
+		value, err := strconv.Unquote(tags[:i+1])
+		if err != nil {
+			break
+		}
+		tags = tags[i+1:]
+
+		r[name] = value
 	}
 	return r
 }
@@ -567,6 +801,14 @@ type OutPutFormat int
 const Yaml OutPutFormat = 1
 const Json OutPutFormat = 2
 
+// resetDocState 重置与文档有关的状态: 解析过的schema(其中有引用component的$ref), components 与自动注册的components
+func (o *OpenApi) resetDocState() {
+	o.schemas = map[string]Schema{}
+	o.schemasDef = map[string]string{}
+	o.autoComponents = ""
+	o.autoSchemas = nil
+}
+
 // 完成openapi, 入口
 func (o *OpenApi) CompleteYaml(inYaml string, typ OutPutFormat) (dest string, err error) {
 	// 读取openapi
@@ -577,6 +819,25 @@ func (o *OpenApi) CompleteYaml(inYaml string, typ OutPutFormat) (dest string, er
 		return "", err
 	}
 
+	// 同一个 OpenApi 可以生成多个文档, 上一个文档中的components不能影响这一个
+	o.resetDocState()
+
+	for _, item := range kv {
+		if item.Key == "openapi" {
+			o.setVersion(fmt.Sprintf("%v", item.Value))
+		}
+	}
+
+	kv, err = o.runBeforeAll(kv)
+	if err != nil {
+		return "", err
+	}
+
+	kv, err = o.loadAutoComponents(kv)
+	if err != nil {
+		return "", err
+	}
+
 	err = o.walkSchemas(kv)
 	if err != nil {
 		return "", err
@@ -587,6 +848,18 @@ func (o *OpenApi) CompleteYaml(inYaml string, typ OutPutFormat) (dest string, er
 		return
 	}
 
+	newKv, err = o.completeAutoComponents(newKv)
+	if err != nil {
+		return
+	}
+
+	newKv = o.completeReadWriteVariants(newKv)
+
+	newKv, err = o.runAfterHooks(newKv)
+	if err != nil {
+		return
+	}
+
 	var out []byte
 	switch typ {
 	case Json:
@@ -643,7 +916,7 @@ func (o *OpenApi) walkSchemas(kv []yaml.MapItem) (err error) {
 			return
 		}
 
-		pat, inProject := o.goparse.FormatPath(pat)
+		pat, inProject := o.formatGoPath(pat)
 		if !inProject {
 			return
 		}
@@ -660,6 +933,7 @@ func (o *OpenApi) parseGoToJsValue(vm *goja.Runtime, value string, yamlKeyRouter
 	if !isGoKey {
 		return vm.ToValue(value), nil
 	}
+	o.goDef = value
 
 	g, exist, err2 := o.getGoStruct(value)
 	if err2 != nil {
@@ -687,15 +961,22 @@ func (o *OpenApi) parseGoToJsValue(vm *goja.Runtime, value string, yamlKeyRouter
 
 }
 
-// key: e.g. x-$path
-func (o *OpenApi) runConfigJs(key string, in []byte, keyRouter []string) (jsBs []byte, err error) {
-	vm := goja.New()
+// configVm 返回运行gopenapi.conf.js的js运行时.
+// 运行时在第一次调用时创建: 注册内置的模块(go, console), 并运行gopenapi.conf.js, 之后的 x-$ 语法, helpers 与钩子都复用它.
+// 所以 gopenapi.conf.js 中的全局变量在一次运行中是共享的.
+func (o *OpenApi) configVm() (vm *goja.Runtime, err error) {
+	if o.vm != nil {
+		return o.vm, nil
+	}
 
-	require.RegisterNativeModule("go", func(runtime *goja.Runtime, module *goja.Object) {
+	vm = goja.New()
+	registry := o.newRegistry()
+
+	registry.RegisterNativeModule("go", func(runtime *goja.Runtime, module *goja.Object) {
 		export := module.Get("exports").(*goja.Object)
 		x := runtime.ToValue(func(arg goja.FunctionCall) goja.Value {
 			goDefPath := arg.Argument(0).String()
-			v, err := o.parseGoToJsValue(vm, goDefPath, keyRouter)
+			v, err := o.parseGoToJsValue(vm, goDefPath, o.keyRouter)
 			if err != nil {
 				log.Errorf("exec parseGoToJsValue func err: %v", err)
 			}
@@ -703,34 +984,191 @@ func (o *OpenApi) runConfigJs(key string, in []byte, keyRouter []string) (jsBs [
 		})
 
 		export.Set("parse", x)
+
+		routes := runtime.ToValue(func(arg goja.FunctionCall) goja.Value {
+			pkgPath := arg.Argument(0).String()
+			rs, err := o.getRoutes(pkgPath)
+			if err != nil {
+				log.Errorf("exec getRoutes func err: %v", err)
+				return nil
+			}
+			if rs == nil {
+				rs = []Route{}
+			}
+
+			bs, _ := json.Marshal(rs)
+			v, err := vm.RunScript("_", fmt.Sprintf("(%s)", bs))
+			if err != nil {
+				log.Errorf("exec getRoutes func err: %v", err)
+				return nil
+			}
+			return v
+		})
+
+		export.Set("routes", routes)
+
+		// 自动包装响应的信封, 见 EnvelopeConfig
+		envelope := runtime.ToValue(func(arg goja.FunctionCall) goja.Value {
+			code := arg.Argument(0).String()
+			e, err := o.envelopeOfCodeJs(code)
+			if err != nil {
+				log.Errorf("exec envelope func err: %v", err)
+				return nil
+			}
+			if e == nil {
+				return goja.Null()
+			}
+
+			bs, _ := json.Marshal(e)
+			v, err := vm.RunScript("_", fmt.Sprintf("(%s)", bs))
+			if err != nil {
+				log.Errorf("exec envelope func err: %v", err)
+				return nil
+			}
+			return v
+		})
+
+		export.Set("envelope", envelope)
+		// gopenapi.conf.js 导出的配置(exports.default), 预设中的方法应该用它读取配置(如 helpers), 而不是预设自己的 exports.
+		// 每次读取时才获取, 因为 go 模块是在配置运行的过程中加载的.
+		config := runtime.ToValue(func(goja.FunctionCall) goja.Value {
+			exports := vm.Get("exports")
+			if exports == nil || goja.IsUndefined(exports) || goja.IsNull(exports) {
+				return goja.Undefined()
+			}
+			return exports.ToObject(vm).Get("default")
+		})
+		_ = export.DefineAccessorProperty("config", config, nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+		// openapi文档的版本, e.g. 3.0.1, 会在 setVersion 时更新
+		export.Set("openapi", o.version)
+		o.goModule = export
+
+		// list, enum, funcs, consts
+		o.registerGoFuncs(runtime, export)
 	})
+	o.registerModules(registry)
 
-	registry := require.NewRegistry()
 	registry.Enable(vm)
 
-	require.RegisterNativeModule("console", console.RequireWithPrinter(console.PrinterFunc(func(s string) {
+	registry.RegisterNativeModule("console", console.RequireWithPrinter(console.PrinterFunc(func(s string) {
 		logn.Printf("gopenapi.conf.js console: %s", s)
 	})))
 
 	console.Enable(vm)
+	o.enableCallDepthLimit(vm)
 
 	_, err = vm.RunScript("builtin", "var exports = {};")
 	if err != nil {
 		err = fmt.Errorf("run builtin err: %w", err)
 		return
 	}
-	_, err = vm.RunScript("gopenapi.conf.js", o.jsConfig)
+	_, err = o.runLimited(vm, "", func() (goja.Value, error) {
+		return vm.RunProgram(o.jsConfig)
+	})
 	if err != nil {
-		err = fmt.Errorf("run gopenapi.conf.js err: %w", err)
+		err = o.configError(err, "")
 		return
 	}
 
+	o.vm = vm
+	return vm, nil
+}
+
+// setVersion 设置正在处理的openapi文档的版本, 同时更新js中的 go.openapi
+func (o *OpenApi) setVersion(version string) {
+	o.version = version
+	if o.goModule != nil {
+		o.goModule.Set("openapi", version)
+	}
+}
+
+// loadConfig 读取 gopenapi.conf.js 中导出的配置:
+//   - typeMapping: 与内置的类型映射合并.
+//   - jsonRequired: 是否将json tag中没有omitempty的字段当做必须的字段, 默认为false.
+//   - readWriteVariants: 是否生成只读/只写的component变体, 默认为false.
+//   - envelope: 响应的信封, 见 EnvelopeConfig.
+//   - helpers: 可以在注释与 x-$ 语法中调用的方法, 见 helperFunc.
+//   - beforeAll, onOperation, onSchema, afterAll: 生命周期钩子, 见 hooks.go.
+// e.g.
+//   export default {
+//     typeMapping: {'github.com/shopspring/decimal.Decimal': {type: 'string'}},
+//     jsonRequired: true,
+//     filter: ...
+//   }
+func (o *OpenApi) loadConfig() error {
+	vm, err := o.configVm()
+	if err != nil {
+		return err
+	}
+
+	v, err := o.runLimited(vm, "", func() (goja.Value, error) {
+		return vm.RunScript("export", `JSON.stringify({
+  typeMapping: exports.default.typeMapping || {},
+  jsonRequired: !!exports.default.jsonRequired,
+  readWriteVariants: !!exports.default.readWriteVariants,
+  envelope: exports.default.envelope || {},
+  helpers: Object.keys(exports.default.helpers || {}),
+  hooks: ['beforeAll', 'onOperation', 'onSchema', 'afterAll'].filter(function (k) {
+    return typeof exports.default[k] === 'function'
+  }),
+  limits: exports.default.limits || {},
+})`)
+	})
+	if err != nil {
+		return o.configError(err, "")
+	}
+
+	var conf struct {
+		TypeMapping       TypeMapping    `json:"typeMapping"`
+		JsonRequired      bool           `json:"jsonRequired"`
+		ReadWriteVariants bool           `json:"readWriteVariants"`
+		Envelope          EnvelopeConfig `json:"envelope"`
+		Helpers           []string       `json:"helpers"`
+		Hooks             []string       `json:"hooks"`
+		Limits            Limits         `json:"limits"`
+	}
+	// 没有配置的限制使用默认值
+	conf.Limits = defaultLimits
+	err = json.Unmarshal([]byte(v.String()), &conf)
+	if err != nil {
+		return fmt.Errorf("invalid gopenapi.conf.js options: %w", err)
+	}
+
+	o.typeMapping = newTypeMapping(conf.TypeMapping)
+	o.jsonRequired = conf.JsonRequired
+	o.readWriteVariants = conf.ReadWriteVariants
+	o.envelope = conf.Envelope
+	o.helpers = map[string]bool{}
+	for _, h := range conf.Helpers {
+		o.helpers[h] = true
+	}
+	o.hooks = map[string]bool{}
+	for _, h := range conf.Hooks {
+		o.hooks[h] = true
+	}
+	o.limits = conf.Limits
+	return nil
+}
+
+// key: e.g. x-$path
+func (o *OpenApi) runConfigJs(key string, in []byte, keyRouter []string) (jsBs []byte, err error) {
+	vm, err := o.configVm()
+	if err != nil {
+		return
+	}
+
+	parentRouter, parentDef := o.keyRouter, o.goDef
+	o.keyRouter, o.goDef = keyRouter, ""
+	defer func() { o.keyRouter, o.goDef = parentRouter, parentDef }()
+
 	krBs, _ := json.Marshal(keyRouter)
 	code := fmt.Sprintf(`var r = exports.default.filter("%s", %s, %s); JSON.stringify(r)`, key, in, krBs)
 	//log.Infof("%s", code)
-	v, err := vm.RunScript("export", code)
+	v, err := o.runLimited(vm, key, func() (goja.Value, error) {
+		return vm.RunScript("export", code)
+	})
 	if err != nil {
-		err = fmt.Errorf("run export err: %w", err)
+		err = o.configError(err, key)
 		return
 	}
 
@@ -799,14 +1237,22 @@ func (o *OpenApi) completeYaml(in []yaml.MapItem, keyRouter []string) (out []yam
 		// x-$xxx 语法, 将调用Js
 		if strings.HasPrefix(key, "x-$") {
 			j := deepYamlToJson(outV.Value)
-			inbs, err2 := json.Marshal(j)
+
+			// 注册了 Directive 的key由go处理, 其他的交给 filter
+			outBs, ok, err2 := o.runDirective(key, j, keyRouter)
 			if err2 != nil {
 				return nil, err2
 			}
+			if !ok {
+				inbs, err2 := json.Marshal(j)
+				if err2 != nil {
+					return nil, err2
+				}
 
-			outBs, err2 := o.runConfigJs(key, inbs, keyRouter)
-			if err2 != nil {
-				return nil, err2
+				outBs, err2 = o.runConfigJs(key, inbs, keyRouter)
+				if err2 != nil {
+					return nil, err2
+				}
 			}
 
 			orderJson, err2 := jsonordered.UnmarshalToOrderJson(outBs)
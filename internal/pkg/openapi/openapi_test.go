@@ -2,12 +2,53 @@ package openapi
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"os"
+	"path"
+	"reflect"
+	"strings"
 	"testing"
 )
 
+// testConfig 返回替换了部分内容的默认配置 gopenapi.conf.js, replacements 是成对的 旧内容, 新内容.
+// 旧内容必须存在, 否则测试会在默认配置上运行而不报错.
+func testConfig(t *testing.T, replacements ...string) string {
+	t.Helper()
+	if len(replacements)%2 != 0 {
+		t.Fatalf("replacements should be pairs of old and new, got %d strings", len(replacements))
+	}
+	bs, err := ioutil.ReadFile("../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := string(bs)
+	for i := 0; i < len(replacements); i += 2 {
+		if !strings.Contains(conf, replacements[i]) {
+			t.Fatalf("can't find %q in gopenapi.conf.js", replacements[i])
+		}
+		conf = strings.Replace(conf, replacements[i], replacements[i+1], 1)
+	}
+	return conf
+}
+
+// newTestOpenApi 使用 testConfig 返回的配置创建 OpenApi, 配置文件写在 t.TempDir() 中
+func newTestOpenApi(t *testing.T, replacements ...string) *OpenApi {
+	t.Helper()
+	confFile := path.Join(t.TempDir(), "gopenapi.conf.js")
+	err := ioutil.WriteFile(confFile, []byte(testConfig(t, replacements...)), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := NewOpenApi("../../../go.mod", confFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
 func TestRunJsExpress(t *testing.T) {
 	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
 	if err != nil {
@@ -34,7 +75,7 @@ func TestCompleteOpenapi(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	dest, err := openAPi.CompleteYaml(string(bs))
+	dest, err := openAPi.CompleteYaml(string(bs), Yaml)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -94,7 +135,7 @@ func TestGetGoDocForStruct(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	d, exist, err := openAPi.getGoStruct("github.com/gopenapi/gopenapi/internal/delivery/http/handler.Boo")
+	d, exist, err := openAPi.getGoStruct("github.com/gopenapi/gopenapi/internal/delivery/http/handler.OtherHandler.Boo")
 	if err != nil {
 		return
 	}
@@ -142,3 +183,1657 @@ components:
 
 	t.Logf("%+v", openAPi.schemas)
 }
+
+func TestGetRoutes(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := openAPi.getRoutes("./internal/delivery/http/handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Route{
+		{Method: "get", Path: "/pet/findByStatus", Key: "github.com/gopenapi/gopenapi/internal/delivery/http/handler.PetHandler.FindPetByStatus"},
+		{Method: "get", Path: "/pet/{id}", Key: "github.com/gopenapi/gopenapi/internal/delivery/http/handler.PetHandler.GetPet"},
+		{Method: "put", Path: "/pet", Key: "github.com/gopenapi/gopenapi/internal/delivery/http/handler.PetHandler.PutPet"},
+		{Method: "delete", Path: "/pet/{id}", Key: "github.com/gopenapi/gopenapi/internal/delivery/http/handler.PetHandler.DelPet"},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Fatalf("want: %+v, got: %+v", want, routes)
+	}
+}
+
+func TestCompletePaths(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest, err := openAPi.CompleteYaml(`
+paths:
+  x-$paths: ./internal/delivery/http/handler
+`, Yaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Paths map[string]map[string]interface{} `yaml:"paths"`
+	}
+	err = yaml.Unmarshal([]byte(dest), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p, methods := range map[string][]string{
+		"/pet/findByStatus": {"get"},
+		"/pet/{id}":         {"get", "delete"},
+		"/pet":              {"put"},
+	} {
+		for _, m := range methods {
+			if _, ok := out.Paths[p][m]; !ok {
+				t.Fatalf("missing operation '%s %s' in: %s", m, p, dest)
+			}
+		}
+	}
+}
+
+// 测试引用了其他包(标准库或依赖的module)中的类型
+func TestExternalPkgSchema(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, exist, err := openAPi.getGoStruct("github.com/gopenapi/gopenapi/internal/model.Order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exist {
+		t.Fatal("not exist")
+	}
+
+	shipDate, _ := g.Schema.(*ObjectSchema).Properties.Get("ShipDate")
+	if _, ok := shipDate.(ObjectProp).Schema.(*ErrSchema); ok {
+		bs, _ := json.Marshal(shipDate)
+		t.Fatalf("can't resolve time.Time: %s", bs)
+	}
+}
+
+// 包路径中含有"."的类型, e.g. gopkg.in/yaml.v2
+func TestDottedPkgPath(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, exist, err := openAPi.getGoStruct("gopkg.in/yaml.v2.MapItem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exist {
+		t.Fatal("not exist")
+	}
+	if _, ok := g.Schema.(*ObjectSchema).Properties.Get("Key"); !ok {
+		bs, _ := json.Marshal(g.Schema)
+		t.Fatalf("unexpected schema: %s", bs)
+	}
+
+	pkg, name := splitPkgPath("gopkg.in/yaml.v2.MapSlice[gopkg.in/yaml.v2.MapItem]")
+	if pkg != "gopkg.in/yaml.v2" || name != "MapSlice[gopkg.in/yaml.v2.MapItem]" {
+		t.Errorf("unexpected split: %s, %s", pkg, name)
+	}
+	if n := schemaName("gopkg.in/yaml.v2.MapItem"); n != "MapItem" {
+		t.Errorf("unexpected schema name: %s", n)
+	}
+}
+
+// 别名与原类型是同一个类型, 使用同一个component
+func TestAliasIdentity(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+components:
+  x-$autoComponents: Name
+  schemas:
+    Pet:
+      x-$schema: ./internal/model.Pet
+    Test:
+      x-$schema: ./internal/model.TestAliasIdentity
+`, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	wants := []string{
+		`"pet":{"$ref":"#/components/schemas/Pet"}`,
+		`"alias":{"$ref":"#/components/schemas/Pet"}`,
+		`"items":{"$ref":"#/components/schemas/PageOfMapItem"}`,
+		`"raw":{"$ref":"#/components/schemas/PageOfMapItem"}`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+}
+
+func TestTypeMapping(t *testing.T) {
+	openAPi := newTestOpenApi(t, "typeMapping: {},", "typeMapping: {'time.Time': {type: 'integer', format: 'unix'}},")
+
+	g, _, err := openAPi.getGoStruct("github.com/gopenapi/gopenapi/internal/model.Order")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		// 覆盖了内置的映射
+		"ShipDate": `{"type":"integer","format":"unix","x-schema":true}`,
+		// 类型注释中的$schema
+		"Price": `{"type":"string","format":"decimal","description":"Money is encoded as a decimal string","x-schema":true}`,
+	}
+	for field, want := range cases {
+		p, _ := g.Schema.(*ObjectSchema).Properties.Get(field)
+		bs, err := json.Marshal(p.(ObjectProp).Schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != want {
+			t.Fatalf("field %s, want: %s, got: %s", field, want, bs)
+		}
+	}
+}
+
+func TestMarshaler(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, _, err := openAPi.getGoStruct("github.com/gopenapi/gopenapi/internal/model.TestMarshaler")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		// MarshalText
+		"Id": `{"type":"string","description":"TestID 被序列化为36进制的字符串","x-schema":true}`,
+		// MarshalJSON 但没有$schema
+		"Raw": `{"x-schema":true,"x-any":true,"description":"TestRaw 自定义了json序列化, 但是没有写$schema","oneOf":[{"type":"array"},{"type":"boolean"},{"type":"integer"},{"type":"number"},{"type":"object"},{"type":"string"}]}`,
+	}
+	for field, want := range cases {
+		p, _ := g.Schema.(*ObjectSchema).Properties.Get(field)
+		bs, err := json.Marshal(p.(ObjectProp).Schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != want {
+			t.Errorf("field %s, want: %s, got: %s", field, want, bs)
+		}
+	}
+}
+
+func TestCompositeTypes(t *testing.T) {
+	openAPi := newTestOpenApi(t, "jsonRequired: false,", "jsonRequired: true,")
+
+	g, _, err := openAPi.getGoStruct("github.com/gopenapi/gopenapi/internal/model.TestTypes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props := g.Schema.(*ObjectSchema).Properties
+	cases := map[string]string{
+		"Labels":  `{"type":"object","additionalProperties":{"type":"string","x-schema":true},"x-schema":true}`,
+		"Extra":   `{"type":"object","additionalProperties":{"x-schema":true,"x-any":true,"oneOf":[{"type":"array"},{"type":"boolean"},{"type":"integer"},{"type":"number"},{"type":"object"},{"type":"string"}]},"x-schema":true}`,
+		"Point":   `{"type":"array","items":{"type":"integer","format":"int64","x-schema":true},"minItems":3,"maxItems":3,"x-schema":true}`,
+		"Paren":   `{"type":"string","x-schema":true}`,
+		"Address": `{"type":"object","description":"Address 匿名结构体","properties":{"City":{"schema":{"type":"string","x-schema":true},"tag":{"json":"city"}}},"required":["city"],"x-schema":true}`,
+		"Alias":   `{"type":"string","default":"available","enum":["available","pending","sold"],"x-enum-varnames":["AvailablePet","PendingPet","SoldPet"],"x-schema":true}`,
+	}
+	for field, want := range cases {
+		p, exist := props.Get(field)
+		if !exist {
+			t.Fatalf("field %s not found", field)
+		}
+		bs, err := json.Marshal(p.(ObjectProp).Schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != want {
+			t.Errorf("field %s, want: %s, got: %s", field, want, bs)
+		}
+	}
+
+	// func与chan字段会被忽略
+	for _, field := range []string{"Callback", "Events"} {
+		if _, exist := props.Get(field); exist {
+			t.Errorf("field %s should be skipped", field)
+		}
+	}
+}
+
+func TestAnyType(t *testing.T) {
+	openAPi := newTestOpenApi(t, "jsonRequired: false,", "jsonRequired: true,")
+
+	g, _, err := openAPi.getGoStruct("github.com/gopenapi/gopenapi/internal/model.TestAny")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anySchema := `{"x-schema":true,"x-any":true,"oneOf":[{"type":"array"},{"type":"boolean"},{"type":"integer"},{"type":"number"},{"type":"object"},{"type":"string"}]}`
+	props := g.Schema.(*ObjectSchema).Properties
+	cases := map[string]string{
+		"Data":  anySchema,
+		"Extra": `{"type":"object","additionalProperties":` + anySchema + `,"x-schema":true}`,
+	}
+	for field, want := range cases {
+		p, _ := props.Get(field)
+		bs, err := json.Marshal(p.(ObjectProp).Schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != want {
+			t.Errorf("field %s, want: %s, got: %s", field, want, bs)
+		}
+	}
+
+	// Page[any] 的元素允许任意类型
+	p, _ := props.Get("Page")
+	items, _ := p.(ObjectProp).Schema.(*ObjectSchema).Properties.Get("Items")
+	bs, err := json.Marshal(items.(ObjectProp).Schema.(*ArraySchema).Items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), `"x-any":true`) {
+		t.Errorf("items of Page[any] should be any, got: %s", bs)
+	}
+
+	key, _ := openAPi.formatGoPath("github.com/gopenapi/gopenapi/internal/model.Page[any]")
+	if want := "github.com/gopenapi/gopenapi/internal/model.Page[any]"; key != want {
+		t.Errorf("key of Page[any], want: %s, got: %s", want, key)
+	}
+	if name := schemaName(key); name != "PageOfAny" {
+		t.Errorf("schema name of Page[any], want: PageOfAny, got: %s", name)
+	}
+}
+
+func TestGenerics(t *testing.T) {
+	openAPi := newTestOpenApi(t, "jsonRequired: false,", "jsonRequired: true,")
+
+	toJson := func(s interface{}) string {
+		bs, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(bs)
+	}
+
+	t.Run("field", func(t *testing.T) {
+		g, _, err := openAPi.getGoStruct("github.com/gopenapi/gopenapi/internal/model.PetPage")
+		if err != nil {
+			t.Fatal(err)
+		}
+		props := g.Schema.(*ObjectSchema).Properties
+
+		p, _ := props.Get("Counts")
+		want := `{"type":"object","description":"Pair test for generic type with multiple type parameters","properties":{"Key":{"schema":{"type":"string","x-schema":true},"tag":{"json":"key"}},"Value":{"schema":{"type":"object","description":"Page is a page of items","properties":{"Items":{"schema":{"type":"array","description":"Items of current page","items":{"type":"integer","format":"int64","description":"Items of current page","x-schema":true},"x-schema":true},"tag":{"json":"items"}},"Total":{"schema":{"type":"integer","format":"int64","x-schema":true},"tag":{"json":"total"}}},"required":["items","total"],"x-schema":true},"tag":{"json":"value"}}},"required":["key","value"],"x-schema":true}`
+		if got := toJson(p.(ObjectProp).Schema); got != want {
+			t.Fatalf("want: %s, got: %s", want, got)
+		}
+
+		p, _ = props.Get("Tags")
+		items, _ := p.(ObjectProp).Schema.(*ObjectSchema).Properties.Get("Items")
+		if _, ok := items.(ObjectProp).Schema.(*ArraySchema).Items.(*ObjectSchema); !ok {
+			t.Fatalf("items of Page[Tag] should be object, got: %s", toJson(items))
+		}
+	})
+
+	t.Run("meta", func(t *testing.T) {
+		if !openAPi.guessIsJs("model.Page[model.Pet]", "github.com/gopenapi/gopenapi/internal/delivery/http/handler/pet.go") {
+			t.Fatal("model.Page[model.Pet] should be js")
+		}
+
+		v, err := openAPi.runJsExpress("model.Page[model.Pet]", "github.com/gopenapi/gopenapi/internal/delivery/http/handler/pet.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := v.(*GoExprWithPath)
+		if g.key != "github.com/gopenapi/gopenapi/internal/model.Page[github.com/gopenapi/gopenapi/internal/model.Pet]" {
+			t.Fatalf("unexpected key: %s", g.key)
+		}
+
+		v, err = openAPi.runJsExpress("model.Pair[string, model.Tag]", "github.com/gopenapi/gopenapi/internal/delivery/http/handler/pet.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := openAPi.anyToSchema(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, _ := s.(*ObjectSchema).Properties.Get("Key")
+		if got := toJson(key.(ObjectProp).Schema); got != `{"type":"string","x-schema":true}` {
+			t.Fatalf("unexpected schema of Key: %s", got)
+		}
+	})
+
+	t.Run("x-$schema", func(t *testing.T) {
+		g, exist, err := openAPi.getGoStruct("./internal/model.Page[./internal/model.Tag]")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !exist {
+			t.Fatal("not found")
+		}
+		items, _ := g.Schema.(*ObjectSchema).Properties.Get("Items")
+		item := items.(ObjectProp).Schema.(*ArraySchema).Items.(*ObjectSchema)
+		if _, ok := item.Properties.Get("name"); !ok {
+			if _, ok := item.Properties.Get("Name"); !ok {
+				t.Fatalf("unexpected items: %s", toJson(item))
+			}
+		}
+
+		fp, _ := openAPi.formatGoPath("./internal/model.Page[Pet]")
+		if fp != "github.com/gopenapi/gopenapi/internal/model.Page[github.com/gopenapi/gopenapi/internal/model.Pet]" {
+			t.Fatalf("unexpected path: %s", fp)
+		}
+	})
+}
+
+func TestSchemaName(t *testing.T) {
+	m := "github.com/gopenapi/gopenapi/internal/model"
+	cases := map[string]string{
+		m + ".Pet":                                 "Pet",
+		m + ".Page[" + m + ".Pet]":                 "PageOfPet",
+		m + ".Pair[string," + m + ".Pet]":          "PairOfStringAndPet",
+		m + ".Page[[]" + m + ".Pet]":               "PageOfPetList",
+		m + ".Page[map[string]" + m + ".Pet]":      "PageOfPetMap",
+		m + ".Page[" + m + ".Page[" + m + ".Pet]]": "PageOfPageOfPet",
+	}
+	for key, want := range cases {
+		if got := schemaName(key); got != want {
+			t.Errorf("schemaName(%s), want: %s, got: %s", key, want, got)
+		}
+	}
+}
+
+func TestValidateTag(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 只测试 binding/validate 中的 required
+	openAPi.jsonRequired = false
+
+	g, _, err := openAPi.getGoStruct("github.com/gopenapi/gopenapi/internal/model.TestValidate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj := g.Schema.(*ObjectSchema)
+	if !reflect.DeepEqual(obj.Required, []string{"name", "tags"}) {
+		t.Fatalf("unexpected required: %v", obj.Required)
+	}
+
+	cases := map[string]string{
+		"Name":   `{"type":"string","x-schema":true,"minLength":1,"maxLength":100}`,
+		"Age":    `{"type":"integer","format":"int64","x-schema":true,"minimum":0,"maximum":150,"exclusiveMaximum":true}`,
+		"Email":  `{"type":"string","format":"email","x-schema":true}`,
+		"Color":  `{"type":"string","enum":["red","green","light blue"],"x-schema":true}`,
+		"Level":  `{"type":"integer","format":"int64","enum":[1,2,3],"x-schema":true}`,
+		"Tags":   `{"type":"array","items":{"type":"string","format":"uuid","x-schema":true},"minItems":1,"x-schema":true}`,
+		"Labels": `{"type":"object","additionalProperties":{"type":"string","x-schema":true},"maxProperties":10,"x-schema":true}`,
+		"Code":   `{"type":"string","x-schema":true,"minLength":6,"maxLength":6}`,
+		// 字符串的eq比较的是值, ne无法表示
+		"Kind":  `{"type":"string","enum":["pet"],"x-schema":true}`,
+		"Other": `{"type":"string","x-schema":true}`,
+		"Score": `{"type":"number","format":"double","x-schema":true,"minimum":0,"exclusiveMinimum":true}`,
+		// 有不是数字的值时 oneof 会被忽略
+		"Mixed": `{"type":"integer","format":"int64","x-schema":true}`,
+		// Low 与 High 是同一个类型, Low 的规则不应该影响 High
+		"Low":  `{"type":"integer","format":"int64","description":"TestLevel 测试iota枚举","default":0,"enum":[0,1],"x-schema":true}`,
+		"High": `{"type":"integer","format":"int64","description":"TestLevel 测试iota枚举","default":0,"enum":[0,1,2,3],"x-enum-varnames":["TestLevelLow","TestLevelMid","TestLevelHigh","TestLevelMax"],"x-enum-descriptions":["TestLevelLow is the lowest level","middle level","",""],"x-schema":true}`,
+	}
+	for field, want := range cases {
+		p, _ := obj.Properties.Get(field)
+		bs, err := json.Marshal(p.(ObjectProp).Schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != want {
+			t.Errorf("field %s, want: %s, got: %s", field, want, bs)
+		}
+	}
+}
+
+func TestJsonTag(t *testing.T) {
+	openAPi := newTestOpenApi(t, "jsonRequired: false,", "jsonRequired: true,")
+
+	g, _, err := openAPi.getGoStruct("github.com/gopenapi/gopenapi/internal/model.TestJson")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj := g.Schema.(*ObjectSchema)
+	var keys []string
+	for _, p := range obj.Properties {
+		keys = append(keys, p.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"Id", "Name", "Note", "X", "Y", "Category"}) {
+		t.Fatalf("unexpected properties: %v", keys)
+	}
+	if !reflect.DeepEqual(obj.Required, []string{"id", "X", "Y", "category"}) {
+		t.Fatalf("unexpected required: %v", obj.Required)
+	}
+
+	id, _ := obj.Properties.Get("Id")
+	if typ := id.(ObjectProp).Schema.(*IdentSchema).Type; typ != "string" {
+		t.Fatalf("type of Id should be string, got: %s", typ)
+	}
+
+	note, _ := obj.Properties.Get("Note")
+	if tag := note.(ObjectProp).Tag; !reflect.DeepEqual(tag, map[string]string{"json": ",omitempty", "example": "a: b c"}) {
+		t.Fatalf("unexpected tag: %v", tag)
+	}
+
+	// 关闭 jsonRequired
+	openAPi.jsonRequired = false
+	openAPi.schemas = map[string]Schema{}
+	g, _, err = openAPi.getGoStruct("github.com/gopenapi/gopenapi/internal/model.TestJson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := g.Schema.(*ObjectSchema).Required; len(r) != 0 {
+		t.Fatalf("required should be empty, got: %v", r)
+	}
+}
+
+func TestBaseTypes(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, _, err := openAPi.getGoStruct("github.com/gopenapi/gopenapi/internal/model.TestNumbers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props := g.Schema.(*ObjectSchema).Properties
+	cases := map[string]string{
+		"Int":     `{"type":"integer","format":"int64","x-schema":true}`,
+		"Int8":    `{"type":"integer","format":"int32","x-schema":true,"minimum":-128,"maximum":127}`,
+		"Uint":    `{"type":"integer","format":"int64","x-schema":true,"minimum":0}`,
+		"Uint8":   `{"type":"integer","format":"int32","x-schema":true,"minimum":0,"maximum":255}`,
+		"Float32": `{"type":"number","format":"float","x-schema":true}`,
+		"Float64": `{"type":"number","format":"double","x-schema":true}`,
+		"Bytes":   `{"type":"string","format":"byte","x-schema":true}`,
+		"Name":    `{"type":"string","nullable":true,"x-schema":true}`,
+	}
+	for field, want := range cases {
+		p, _ := props.Get(field)
+		bs, err := json.Marshal(p.(ObjectProp).Schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != want {
+			t.Errorf("field %s, want: %s, got: %s", field, want, bs)
+		}
+	}
+
+	// 复数不能被json序列化
+	if _, exist := props.Get("Complex"); exist {
+		t.Errorf("field Complex should be omitted")
+	}
+}
+
+func TestNullable(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := `
+openapi: %s
+components:
+  schemas:
+    Tag:
+      x-$schema: ./internal/model.Tag
+    TestNumbers:
+      x-$schema: ./internal/model.TestNumbers
+`
+	cases := map[string][]string{
+		"3.0.1": {`"type":"string","nullable":true`, `"allOf":[{"$ref":"#/components/schemas/Tag"}],"nullable":true`},
+		"3.1.0": {`"type":["string","null"]`, `"anyOf":[{"$ref":"#/components/schemas/Tag"},{"type":"null"}]`},
+	}
+	for version, wants := range cases {
+		out, err := openAPi.CompleteYaml(fmt.Sprintf(src, version), Json)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = strings.Join(strings.Fields(out), "")
+		for _, want := range wants {
+			if !strings.Contains(out, want) {
+				t.Errorf("openapi %s, output should contains: %s, got: %s", version, want, out)
+			}
+		}
+	}
+}
+
+func TestExclusiveRange(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := `
+openapi: %s
+components:
+  schemas:
+    TestValidate:
+      x-$schema: ./internal/model.TestValidate
+`
+	// Age: gte=0,lt=150, Score: gt=0
+	cases := map[string][]string{
+		"3.0.1": {`"minimum":0,"maximum":150,"exclusiveMaximum":true`, `"minimum":0,"exclusiveMinimum":true`},
+		"3.1.0": {`"minimum":0,"exclusiveMaximum":150`, `"format":"double","exclusiveMinimum":0`},
+	}
+	for version, wants := range cases {
+		out, err := openAPi.CompleteYaml(fmt.Sprintf(src, version), Json)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = strings.Join(strings.Fields(out), "")
+		for _, want := range wants {
+			if !strings.Contains(out, want) {
+				t.Errorf("openapi %s, output should contains: %s, got: %s", version, want, out)
+			}
+		}
+	}
+}
+
+func TestEnum(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, _, err := openAPi.getGoStruct("github.com/gopenapi/gopenapi/internal/model.TestConsts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props := g.Schema.(*ObjectSchema).Properties
+	cases := map[string]string{
+		"Level":  `{"type":"integer","format":"int64","description":"TestLevel 测试iota枚举","default":0,"enum":[0,1,2,3],"x-enum-varnames":["TestLevelLow","TestLevelMid","TestLevelHigh","TestLevelMax"],"x-enum-descriptions":["TestLevelLow is the lowest level","middle level","",""],"x-schema":true}`,
+		"Prefix": `{"type":"string","description":"TestPrefix 测试字符串拼接","default":"pet_dog","enum":["pet_dog","pet_cat"],"x-enum-varnames":["TestPrefixDog","TestPrefixCat"],"x-schema":true}`,
+	}
+	for field, want := range cases {
+		p, _ := props.Get(field)
+		bs, err := json.Marshal(p.(ObjectProp).Schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != want {
+			t.Errorf("field %s, want: %s, got: %s", field, want, bs)
+		}
+	}
+}
+
+func TestOneOf(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := `
+openapi: 3.0.1
+components:
+  schemas:
+    Circle:
+      x-$schema: ./internal/model.TestCircle
+    Square:
+      x-$schema: ./internal/model.TestSquare
+    Shapes:
+      x-$schema: ./internal/model.TestShapes
+`
+	out, err := openAPi.CompleteYaml(src, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	wants := []string{
+		// 自动查找实现
+		`"shape":{"description":"TestShape测试接口的oneOf,成员是包中实现了接口的类型","oneOf":[{"$ref":"#/components/schemas/Circle"},{"$ref":"#/components/schemas/Square"}],"discriminator":{"propertyName":"kind","mapping":{"circle":"#/components/schemas/Circle","Square":"#/components/schemas/Square"}}}`,
+		`"shapes":{"type":"array","items":{"description":"TestShape测试接口的oneOf,成员是包中实现了接口的类型","oneOf":[{"$ref":"#/components/schemas/Circle"}`,
+		// 注释中指定
+		`"figure":{"description":"TestFigure测试在注释中指定oneOf","oneOf":[{"$ref":"#/components/schemas/Circle"},{"$ref":"#/components/schemas/Square"}],"discriminator":{"propertyName":"kind","mapping":{"c":"#/components/schemas/Circle","s":"#/components/schemas/Square"}}}`,
+		// 空接口
+		`"any":{"oneOf":[{"type":"array"}`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+}
+
+func TestAutoComponents(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := `
+openapi: 3.0.1
+components:
+  x-$autoComponents: PkgName
+  schemas:
+    Pet:
+      x-$schema: ./internal/model.Pet
+`
+	out, err := openAPi.CompleteYaml(src, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	wants := []string{
+		`"category":{"$ref":"#/components/schemas/ModelCategory"}`,
+		`"items":{"$ref":"#/components/schemas/ModelTag"}`,
+		`"ModelCategory":{"type":"object","properties":{"id":`,
+		`"ModelTag":{"type":"object","properties":{"id":`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "x-$autoComponents") {
+		t.Errorf("x-$autoComponents should be removed, got: %s", out)
+	}
+
+	// 同一个 OpenApi 生成的下一个文档不会继承上一个文档的components
+	out2, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+components:
+  schemas:
+    Pet:
+      x-$schema: ./internal/model.Pet
+`, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out2, "ModelCategory") {
+		t.Errorf("components of the previous document should not be generated, got: %s", out2)
+	}
+
+	again, err := openAPi.CompleteYaml(src, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again = strings.Join(strings.Fields(again), ""); again != out {
+		t.Errorf("the same document should have the same output, want: %s, got: %s", out, again)
+	}
+}
+
+func TestComponentName(t *testing.T) {
+	cases := []struct {
+		naming string
+		keys   []string
+		want   []string
+	}{
+		{
+			naming: "Name",
+			keys:   []string{"github.com/a/model.Pet", "github.com/b/model.Pet", "github.com/a/model.Page[github.com/a/model.Pet]"},
+			want:   []string{"Pet", "ModelPet", "PageOfPet"},
+		},
+		{
+			naming: "PkgName",
+			keys:   []string{"github.com/a/model.Pet", "github.com/b/model.Pet", "github.com/c-d/model.Pet"},
+			want:   []string{"ModelPet", "BModelPet", "CDModelPet"},
+		},
+		{
+			naming: "pkg.Name",
+			keys:   []string{"github.com/a/model.Pet", "github.com/b/model.Pet"},
+			want:   []string{"model.Pet", "b.model.Pet"},
+		},
+	}
+
+	for _, c := range cases {
+		o := &OpenApi{autoComponents: c.naming}
+		// 名字与遇到类型的顺序无关
+		reversed := make([]string, len(c.keys))
+		for i, key := range c.keys {
+			reversed[len(c.keys)-1-i] = key
+		}
+		for _, keys := range [][]string{c.keys, reversed} {
+			names := o.componentNames(keys, map[string]bool{})
+			for i, key := range c.keys {
+				if names[key] != c.want[i] {
+					t.Errorf("naming %s, key %s, want: %s, got: %s", c.naming, key, c.want[i], names[key])
+				}
+			}
+		}
+	}
+
+	// 已经使用了的名字
+	o := &OpenApi{autoComponents: "Name"}
+	names := o.componentNames([]string{"github.com/a/model.Pet"}, map[string]bool{"Pet": true})
+	if names["github.com/a/model.Pet"] != "ModelPet" {
+		t.Errorf("want: ModelPet, got: %s", names["github.com/a/model.Pet"])
+	}
+}
+
+func TestRecursiveTypes(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := `
+openapi: 3.0.1
+components:
+  schemas:
+    Recursive:
+      x-$schema: ./internal/model.TestRecursive
+`
+	out, err := openAPi.CompleteYaml(src, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	wants := []string{
+		`"node":{"$ref":"#/components/schemas/TestNode"}`,
+		`"tree":{"$ref":"#/components/schemas/TestTreeA"}`,
+		// 自身递归
+		`"next":{"allOf":[{"$ref":"#/components/schemas/TestNode"}],"nullable":true}`,
+		// 相互递归
+		`"a":{"type":"array","items":{"$ref":"#/components/schemas/TestTreeA"}}`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "recursive references") {
+		t.Errorf("output should not contains error, got: %s", out)
+	}
+}
+
+func TestRepeatedTypes(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有定义为component的类型在每个字段中都是内联的, 不会被当做递归
+	out, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+components:
+  schemas:
+    Repeated:
+      x-$schema: ./internal/model.TestRepeated
+`, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	category := `{"type":"object","properties":{"id":{"type":"integer","format":"int64"},"name":{"type":"string"}}}`
+	wants := []string{
+		`"a":` + category,
+		`"b":` + category,
+		`"items":{"type":"array","items":` + category + `}`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "$ref") || strings.Contains(out, "recursive references") {
+		t.Errorf("repeated types should not be references, got: %s", out)
+	}
+}
+
+func TestModifier(t *testing.T) {
+	openAPi := newTestOpenApi(t, "jsonRequired: false,", "jsonRequired: true,")
+
+	file := "github.com/gopenapi/gopenapi/internal/model/pet.go"
+	cases := map[string]string{
+		"schema(Category).pick('name')":                      `{"type":"object","properties":{"Name":{"schema":{"type":"string","x-schema":true},"tag":{"json":"name"}}},"required":["name"],"x-schema":true}`,
+		"schema(Category).omit('Name').partial()":            `{"type":"object","properties":{"Id":{"schema":{"type":"integer","format":"int64","x-schema":true},"tag":{"json":"id"}}},"x-schema":true}`,
+		"schema(Category).partial().required('id')":          `{"type":"object","properties":{"Id":{"schema":{"type":"integer","format":"int64","x-schema":true},"tag":{"json":"id"},"required":true},"Name":{"schema":{"type":"string","x-schema":true},"tag":{"json":"name"}}},"required":["id"],"x-schema":true}`,
+		"schema(Category).pick('id').extend({token: 'abc'})": `{"type":"object","properties":{"Id":{"schema":{"type":"integer","format":"int64","x-schema":true},"tag":{"json":"id"}},"token":{"schema":{"type":"string","default":"abc","x-schema":true,"example":"abc"}}},"required":["id"],"x-schema":true}`,
+		// allOf
+		"schema(DelPetParams).omit('manage_pwd')": `{"type":"object","properties":{"Id":{"schema":{"type":"integer","format":"int64","description":"Id of pet to return","x-schema":true},"meta":{"required":true,"in":"path"},"tag":{"uri":"id"}}},"required":["Id"],"x-schema":true}`,
+	}
+	for code, want := range cases {
+		v, err := openAPi.runJsExpress(code, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != want {
+			t.Errorf("%s, want: %s, got: %s", code, want, bs)
+		}
+	}
+}
+
+func TestReadWriteVariants(t *testing.T) {
+	src := `
+openapi: 3.0.1
+paths:
+  /account:
+    put:
+      x-$path: ./internal/delivery/http/handler.OtherHandler.UpdateAccount
+components:
+  schemas:
+    Account:
+      x-$schema: ./internal/model.TestAccount
+    Owner:
+      x-$schema: ./internal/model.TestOwner
+`
+	// 默认只生成 readOnly 与 writeOnly 关键字
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := openAPi.CompleteYaml(src, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	wants := []string{
+		`"schema":{"$ref":"#/components/schemas/Account"}`,
+		`"id":{"type":"integer","format":"int64","readOnly":true}`,
+		`"password":{"type":"string","writeOnly":true}`,
+		`"owner":{"allOf":[{"$ref":"#/components/schemas/Owner"}],"readOnly":true}`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "AccountRead") {
+		t.Errorf("variants should not be generated by default, got: %s", out)
+	}
+
+	openAPi = newTestOpenApi(t,
+		"readWriteVariants: false,", "readWriteVariants: true,",
+		"jsonRequired: false,", "jsonRequired: true,",
+	)
+	out, err = openAPi.CompleteYaml(src, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	wants = []string{
+		`"requestBody":{"description":"body","content":{"application/json":{"schema":{"$ref":"#/components/schemas/AccountWrite"}}}}`,
+		`"schema":{"$ref":"#/components/schemas/AccountRead"}`,
+		`"AccountRead":{"type":"object","description":"TestAccount测试只读与只写的字段","properties":{"id":{"type":"integer","format":"int64","readOnly":true},"name":{"type":"string"},"owner":{"allOf":[{"$ref":"#/components/schemas/OwnerRead"}],"readOnly":true}},"required":["id","name","owner"]}`,
+		`"AccountWrite":{"type":"object","description":"TestAccount测试只读与只写的字段","properties":{"name":{"type":"string"},"password":{"type":"string","writeOnly":true}},"required":["name","password"]}`,
+		`"OwnerRead":{"type":"object","description":"TestOwner被TestAccount引用","properties":{"name":{"type":"string"}},"required":["name"]}`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "OwnerWrite") {
+		t.Errorf("unreferenced variants should not be generated, got: %s", out)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	openAPi := newTestOpenApi(t,
+		"envelope: {type: '', auto: false, error: ''},",
+		"envelope: {type: './internal/model.TestResp', auto: true, error: './internal/model.TestErrResp'},",
+		"jsonRequired: false,", "jsonRequired: true,",
+	)
+
+	out, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+paths:
+  /account:
+    get:
+      x-$path: ./internal/delivery/http/handler.OtherHandler.GetAccount
+`, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	wants := []string{
+		// 2xx 自动使用 type 包装
+		`"200":{"description":"success","content":{"application/json":{"schema":{"type":"object","description":"TestResp测试响应的信封","properties":{"code":{"type":"integer","format":"int64"},"msg":{"type":"string"},"data":{"type":"object","description":"TestAccount`,
+		// wrap() 指定的信封不会被再次包装
+		`"201":{"description":"success","content":{"application/json":{"schema":{"type":"object","description":"TestErrResp测试错误响应的信封","properties":{"code":{"type":"integer","format":"int64"},"error":{"type":"object","description":"TestAccount`,
+		// 错误使用 error 包装
+		`"400":{"description":"success","content":{"application/json":{"schema":{"type":"object","description":"TestErrResp测试错误响应的信封","properties":{"code":{"type":"integer","format":"int64"},"error":{"type":"object","properties":{"reason":{"type":"string"}},"required":["reason"]}},"required":["code","error"]}}}}`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "x-wrapped") {
+		t.Errorf("x-wrapped should be removed, got: %s", out)
+	}
+
+	// 没有配置信封时 wrap() 必须指定信封
+	openAPi, err = NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := "github.com/gopenapi/gopenapi/internal/delivery/http/handler/other.go"
+	_, err = openAPi.runJsExpress("wrap(model.TestAccount)", file)
+	if err == nil || !strings.Contains(err.Error(), "envelope.type") {
+		t.Fatalf("wrap() without envelope should return an error, got: %v", err)
+	}
+	_, err = openAPi.runJsExpress("wrap(model.TestAccount, model.TestError)", file)
+	if err == nil || !strings.Contains(err.Error(), "$payload") {
+		t.Fatalf("envelope without payload field should return an error, got: %v", err)
+	}
+}
+
+func TestRunJsExpressSyntax(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := "github.com/gopenapi/gopenapi/internal/delivery/http/handler/pet.go"
+	cases := map[string]string{
+		"1 > 0 ? model.Pet : model.Tag":               "github.com/gopenapi/gopenapi/internal/model.Pet",
+		"[model.Tag].map((t) => t)[0]":                "github.com/gopenapi/gopenapi/internal/model.Tag",
+		"model['Category']":                           "github.com/gopenapi/gopenapi/internal/model.Category",
+		"model.Page[model.Pet]":                       "github.com/gopenapi/gopenapi/internal/model.Page[github.com/gopenapi/gopenapi/internal/model.Pet]",
+		"({...{pet: model.Pet}})['pet']":              "github.com/gopenapi/gopenapi/internal/model.Pet",
+		"`${'model'}` === 'model' ? model.Pet : null": "github.com/gopenapi/gopenapi/internal/model.Pet",
+	}
+	for code, want := range cases {
+		v, err := openAPi.runJsExpress(code, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, ok := v.(*GoExprWithPath)
+		if !ok {
+			t.Fatalf("%s: want *GoExprWithPath, got: %T", code, v)
+		}
+		if g.key != want {
+			t.Errorf("%s: want: %s, got: %s", code, want, g.key)
+		}
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	openAPi := newTestOpenApi(t,
+		"helpers: {},",
+		`helpers: {
+    paged: function (item) {
+      return {'x-schema': true, type: 'object', properties: {
+        items: {schema: {'x-schema': true, type: 'array', items: item.schema}},
+        total: {schema: {'x-schema': true, type: 'integer'}},
+      }}
+    },
+    errors: function (...codes) {
+      let r = {}
+      codes.forEach((c) => { r[c] = '#' + c })
+      return r
+    },
+    list: (item) => ({type: 'array', items: processSchema(item.schema)}),
+  },`,
+	)
+
+	out, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+paths:
+  /pets:
+    get:
+      x-$path: ./internal/delivery/http/handler.OtherHandler.ListPets
+components:
+  schemas:
+    Pet:
+      x-$schema: ./internal/model.Pet
+    Tags:
+      x-$list: ./internal/model.Tag
+`, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	wants := []string{
+		// 在注释中调用
+		`"200":{"description":"success","content":{"application/json":{"schema":{"properties":{"items":{"items":{"$ref":"#/components/schemas/Pet"},"type":"array"},"total":{"type":"integer"}},"type":"object"}}}}`,
+		`"400":{"$ref":"#/components/responses/400"}`,
+		`"404":{"$ref":"#/components/responses/404"}`,
+		// 通过 x-$name 调用
+		`"Tags":{"type":"array","items":{"type":"object","properties":{"id":`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+}
+
+func TestHooks(t *testing.T) {
+	openAPi := newTestOpenApi(t,
+		"  filter: function",
+		`  beforeAll: (doc) => {
+    doc.info = {title: 'hooks', version: '1.0.0'}
+  },
+  onOperation: (op, {path, method}) => {
+    op.operationId = method + path.split('/').join('_')
+    if (op.security) {
+      op.responses['401'] = {description: 'unauthorized'}
+    }
+  },
+  onSchema: (schema, {name}) => {
+    return Object.assign({title: name}, schema)
+  },
+  afterAll: (doc) => {
+    let paths = {}
+    Object.keys(doc.paths).sort().forEach((p) => { paths[p] = doc.paths[p] })
+    doc.paths = paths
+    doc.components.securitySchemes = {token: {type: 'apiKey', in: 'header', name: 'Authorization'}}
+  },
+  filter: function`,
+	)
+
+	out, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+paths:
+  /pet:
+    put:
+      x-$path: ./internal/delivery/http/handler.PetHandler.PutPet
+      security: [{token: []}]
+  /a:
+    get:
+      x-$path: ./internal/delivery/http/handler.OtherHandler.Boo
+components:
+  schemas:
+    Tag:
+      x-$schema: ./internal/model.Tag
+`, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	wants := []string{
+		`"info":{"title":"hooks","version":"1.0.0"}`,
+		`"paths":{"/a":{"get":{`,
+		`"operationId":"get_a"`,
+		`"operationId":"put_pet"`,
+		`"401":{"description":"unauthorized"}`,
+		`"Tag":{"title":"Tag","type":"object"`,
+		`"securitySchemes":{"token":{"type":"apiKey","in":"header","name":"Authorization"}}`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+	// 只有 /pet 有 security
+	if strings.Count(out, `"401":{"description":"unauthorized"}`) != 1 {
+		t.Errorf("401 should only be added to secured operations, got: %s", out)
+	}
+}
+
+func TestHooksKeepOrder(t *testing.T) {
+	openAPi := newTestOpenApi(t,
+		"  filter: function",
+		`  onOperation: (op) => {
+    op.responses['401'] = {description: 'unauthorized'}
+  },
+  filter: function`,
+	)
+
+	out, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+paths:
+  /pet:
+    get:
+      responses:
+        '404': {description: missing}
+        default: {description: error}
+        '200': {description: ok}
+`, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	// js中整数的key会排在最前面, 钩子不应该改变文档中key的顺序
+	want := `"responses":{"404":{"description":"missing"},"default":{"description":"error"},"200":{"description":"ok"},"401":{"description":"unauthorized"}}`
+	if !strings.Contains(out, want) {
+		t.Errorf("output should contains: %s, got: %s", want, out)
+	}
+}
+
+func TestConfigVmReuse(t *testing.T) {
+	// loads 记录 gopenapi.conf.js 运行了多少次
+	openAPi := newTestOpenApi(t,
+		"export default {",
+		"var loads = 0\nloads++\n\nexport default {",
+		"  helpers: {},",
+		`  helpers: {
+    runtime: () => ({loads, version: go.openapi}),
+  },`,
+	)
+
+	for _, version := range []string{"3.0.1", "3.1.0"} {
+		out, err := openAPi.CompleteYaml(`
+openapi: `+version+`
+paths:
+  /pet:
+    put:
+      x-$path: ./internal/delivery/http/handler.PetHandler.PutPet
+components:
+  schemas:
+    Tag:
+      x-$schema: ./internal/model.Tag
+    Runtime:
+      x-$runtime: []
+`, Json)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = strings.Join(strings.Fields(out), "")
+		want := `"Runtime":{"loads":1,"version":"` + version + `"}`
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+}
+
+func TestConfigError(t *testing.T) {
+	replacements := []string{
+		"  helpers: {},",
+		`  helpers: {
+    boom: (s) => {
+      return s.missing.name
+    },
+  },`,
+	}
+	openAPi := newTestOpenApi(t, replacements...)
+	conf := testConfig(t, replacements...)
+	confFile := openAPi.jsFile
+
+	_, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+components:
+  schemas:
+    Boom:
+      x-$boom: ./internal/model.Tag
+`, Json)
+	var ce *ConfigError
+	if !errors.As(err, &ce) {
+		t.Fatalf("want ConfigError, got: %v", err)
+	}
+
+	// 位置应该是源文件中的位置, 而不是babel转换后的
+	line := strings.Count(conf[:strings.Index(conf, "return s.missing.name")], "\n") + 1
+	if ce.File != confFile || ce.Line != line || ce.Column != 14 {
+		t.Errorf("want position %s:%d:14, got: %s:%d:%d", confFile, line, ce.File, ce.Line, ce.Column)
+	}
+	if ce.Key != "x-$boom" || strings.Join(ce.Route, ".") != "components.schemas.Boom" || ce.GoDef != "./internal/model.Tag" {
+		t.Errorf("unexpected context: %+v", ce)
+	}
+	if !strings.Contains(ce.Frame, fmt.Sprintf("> %d |       return s.missing.name", line)) {
+		t.Errorf("unexpected code frame:\n%s", ce.Frame)
+	}
+	t.Log(err)
+}
+
+func TestConfigOptionsError(t *testing.T) {
+	// 错误中包含出错的配置项
+	cases := map[string][]string{
+		"limits.callTimeout": {
+			"  limits: {callTimeout: 10000, totalTimeout: 120000, maxCallDepth: 1000},",
+			"  limits: {callTimeout: '10s', totalTimeout: 120000, maxCallDepth: 1000},",
+		},
+		"typeMapping": {
+			"  typeMapping: {},",
+			"  typeMapping: ['time.Time'],",
+		},
+	}
+	for key, replacements := range cases {
+		confFile := path.Join(t.TempDir(), "gopenapi.conf.js")
+		err := ioutil.WriteFile(confFile, []byte(testConfig(t, replacements...)), os.ModePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = NewOpenApi("../../../go.mod", confFile)
+		if err == nil || !strings.Contains(err.Error(), "invalid gopenapi.conf.js options") || !strings.Contains(err.Error(), key) {
+			t.Errorf("error should contains the invalid option %s, got: %v", key, err)
+		}
+	}
+}
+
+func TestConfigImport(t *testing.T) {
+	conf, err := ioutil.ReadFile("../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		// 默认配置也可以作为预设使用
+		".gopenapi/presets/base/index.js": string(conf),
+		".gopenapi/presets/company/index.js": `export default {
+  helpers: {
+    company: () => ({from: 'preset'}),
+  },
+}
+`,
+		"lib/local.js": `export function local() {
+  return {from: 'local'}
+}
+
+export function boom(s) {
+  return s.missing.name
+}
+`,
+	}
+	files["gopenapi.conf.js"] = testConfig(t,
+		"export default {",
+		`import preset from 'company'
+import {processSchema as baseSchema} from 'base'
+import {local, boom} from './lib/local'
+
+export default {`,
+		"  helpers: {},",
+		`  helpers: {
+    ...preset.helpers,
+    local: () => local(),
+    base: (s) => baseSchema(s.schema),
+    boom: (s) => boom(s),
+  },`,
+	)
+	for name, content := range files {
+		p := path.Join(dir, name)
+		if err = os.MkdirAll(path.Dir(p), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(p, []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	openAPi, err := NewOpenApi("../../../go.mod", path.Join(dir, "gopenapi.conf.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+components:
+  schemas:
+    Company:
+      x-$company: []
+    Local:
+      x-$local: []
+    Base:
+      x-$base: ./internal/model.Tag
+`, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	wants := []string{
+		`"Company":{"from":"preset"}`,
+		`"Local":{"from":"local"}`,
+		`"Base":{"type":"object"`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+
+	// 引入的文件中的错误也会映射回源文件
+	_, err = openAPi.CompleteYaml(`
+openapi: 3.0.1
+components:
+  schemas:
+    Boom:
+      x-$boom: ./internal/model.Tag
+`, Json)
+	var ce *ConfigError
+	if !errors.As(err, &ce) {
+		t.Fatalf("want ConfigError, got: %v", err)
+	}
+	if ce.File != path.Join(dir, "lib/local.js") || ce.Line != 6 || !strings.Contains(ce.Frame, "> 6 |   return s.missing.name") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestConfigPresetFilter(t *testing.T) {
+	conf, err := ioutil.ReadFile("../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	// filter 来自预设, 但应该使用当前配置的 helpers 与 readWriteVariants, 而不是预设自己的
+	files := map[string]string{
+		".gopenapi/presets/base/index.js": string(conf),
+		"gopenapi.conf.js": `import base from 'base'
+
+export default {
+  ...base,
+  readWriteVariants: true,
+  helpers: {
+    mine: () => ({from: 'config'}),
+  },
+}
+`,
+	}
+	for name, content := range files {
+		p := path.Join(dir, name)
+		if err = os.MkdirAll(path.Dir(p), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(p, []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	openAPi, err := NewOpenApi("../../../go.mod", path.Join(dir, "gopenapi.conf.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+paths:
+  /account:
+    put:
+      x-$path: ./internal/delivery/http/handler.OtherHandler.UpdateAccount
+components:
+  schemas:
+    Mine:
+      x-$mine: []
+    Account:
+      x-$schema: ./internal/model.TestAccount
+`, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	wants := []string{
+		`"Mine":{"from":"config"}`,
+		`"schema":{"$ref":"#/components/schemas/AccountWrite"}`,
+		`"AccountRead":{`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+}
+
+func TestConfigLimits(t *testing.T) {
+	// 配置文件所在目录之外的文件不能被引入
+	outside := path.Join(t.TempDir(), "outside.js")
+	if err := ioutil.WriteFile(outside, []byte("module.exports = {secret: 1}"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	openAPi := newTestOpenApi(t,
+		"  limits: {callTimeout: 10000, totalTimeout: 120000, maxCallDepth: 1000},",
+		"  limits: {callTimeout: 200, totalTimeout: 1000, maxCallDepth: 100},",
+		"  helpers: {},",
+		`  helpers: {
+    spin: () => {
+      while (true) {}
+    },
+    deep: () => {
+      let f = (n) => f(n + 1) + 1
+      return f(0)
+    },
+    sandbox: () => {
+      let modules = ['http', 'child_process', '/etc/passwd'].filter((m) => {
+        try {
+          require(m)
+          return true
+        } catch (e) {
+          return false
+        }
+      })
+      return {modules, fetch: typeof fetch, xhr: typeof XMLHttpRequest, write: typeof require('fs').writeFileSync}
+    },
+    outside: () => require('`+outside+`'),
+  },`,
+	)
+
+	// 死循环会超时, 错误中包含正在运行的key
+	_, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+components:
+  schemas:
+    Spin:
+      x-$spin: []
+`, Json)
+	if err == nil || !strings.Contains(err.Error(), "timeout: 'x-$spin' ran longer than 200ms (limits.callTimeout)") {
+		t.Fatalf("want timeout error, got: %v", err)
+	}
+
+	_, err = openAPi.CompleteYaml(`
+openapi: 3.0.1
+components:
+  schemas:
+    Deep:
+      x-$deep: []
+`, Json)
+	if err == nil || !strings.Contains(err.Error(), "RangeError: Maximum call depth exceeded (100)") {
+		t.Fatalf("want RangeError, got: %v", err)
+	}
+
+	// 运行时仍然可以继续使用
+	out, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+components:
+  schemas:
+    Sandbox:
+      x-$sandbox: []
+`, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	want := `"Sandbox":{"modules":[],"fetch":"undefined","xhr":"undefined","write":"undefined"}`
+	if !strings.Contains(out, want) {
+		t.Errorf("output should contains: %s, got: %s", want, out)
+	}
+
+	_, err = openAPi.CompleteYaml(`
+openapi: 3.0.1
+components:
+  schemas:
+    Outside:
+      x-$outside: []
+`, Json)
+	if err == nil || !strings.Contains(err.Error(), "is outside of the config directory, presets and node_modules") {
+		t.Fatalf("want require error, got: %v", err)
+	}
+
+	// 注释中的js表达式也会超时, 包括其中调用的helper
+	file := "github.com/gopenapi/gopenapi/internal/delivery/http/handler/pet.go"
+	for _, code := range []string{"(() => { while (true) {} })()", "spin()"} {
+		_, err = openAPi.runJsExpress(code, file)
+		if err == nil || !strings.Contains(err.Error(), "ran longer than 200ms (limits.callTimeout)") {
+			t.Fatalf("%s: want timeout error, got: %v", code, err)
+		}
+	}
+	_, err = openAPi.CompleteYaml(`
+openapi: 3.0.1
+components:
+  schemas:
+    Sandbox:
+      x-$sandbox: []
+`, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 超过总时间后不会再运行
+	for i := 0; i < 5; i++ {
+		_, err = openAPi.CompleteYaml(`
+openapi: 3.0.1
+components:
+  schemas:
+    Spin:
+      x-$spin: []
+`, Json)
+	}
+	if err == nil || !strings.Contains(err.Error(), "(limits.totalTimeout)") {
+		t.Fatalf("want total timeout error, got: %v", err)
+	}
+}
+
+func TestConfigModules(t *testing.T) {
+	openAPi := newTestOpenApi(t,
+		"import go from 'go';",
+		`import go from 'go';
+import fs from 'fs';
+import yaml from 'yaml';
+import path from 'path';`,
+		"  helpers: {},",
+		`  helpers: {
+    modules: () => {
+      let outside
+      try {
+        fs.readFileSync('../../go.mod')
+      } catch (e) {
+        outside = e.message
+      }
+      return {
+        files: fs.readdirSync('internal/model').filter((f) => f === 'pet.go'),
+        exists: [fs.existsSync('go.mod'), fs.existsSync('missing.yaml')],
+        keys: Object.keys(yaml.parse(fs.readFileSync('example/example_simple.yaml'))).slice(0, 2),
+        yaml: yaml.stringify({b: 1, a: [1, {d: 2, c: 3}]}),
+        outside,
+        path: [path.join('a', '../b', 'c.yaml'), path.extname('c.yaml'), path.basename('/a/c.yaml', '.yaml')],
+        types: go.list('./internal/model').filter((d) => d.name === 'Pet' || d.name === 'PetStatus').map((d) => [d.name, d.kind]),
+        enum: go.enum('./internal/model.PetStatus').values,
+        funcs: go.funcs('./internal/delivery/http/handler.PetHandler').map((f) => f.name).slice(0, 2),
+        consts: go.consts('./internal/model').filter((c) => c.type === 'TestLevel').map((c) => [c.name, c.value]),
+      }
+    },
+  },`,
+	)
+
+	out, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+components:
+  schemas:
+    Modules:
+      x-$modules: []
+`, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	wants := []string{
+		`"files":["pet.go"]`,
+		`"exists":[true,false]`,
+		`"keys":["openapi","info"]`,
+		`"yaml":"b:1\na:\n-1\n-d:2\nc:3\n"`,
+		`"outside":"fs:'../../go.mod'isoutsideofthemoduleroot`,
+		`"path":["b/c.yaml",".yaml","c"]`,
+		`"types":[["Pet","struct"],["PetStatus","type"]]`,
+		`"enum":["available","pending","sold"]`,
+		`"funcs":["FindPetByStatus","GetPet"]`,
+		`"consts":[["TestLevelLow",0],["TestLevelMid",1],["TestLevelHigh",2]`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contains: %s, got: %s", want, out)
+		}
+	}
+}
+
+type testDirective struct {
+	name   string
+	expand func(ctx *DirectiveContext, value interface{}, route []string) (interface{}, error)
+}
+
+func (d testDirective) Name() string { return d.name }
+
+func (d testDirective) Expand(ctx *DirectiveContext, value interface{}, route []string) (interface{}, error) {
+	return d.expand(ctx, value, route)
+}
+
+func TestDirective(t *testing.T) {
+	openAPi, err := NewOpenApi("../../../go.mod", "../../../gopenapi.conf.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = openAPi.RegisterDirective(
+		testDirective{name: "build", expand: func(ctx *DirectiveContext, value interface{}, route []string) (interface{}, error) {
+			return map[string]interface{}{"version": "1.2.3", "x-key": ctx.Key, "x-openapi": ctx.Version}, nil
+		}},
+		testDirective{name: "routes", expand: func(ctx *DirectiveContext, value interface{}, route []string) (interface{}, error) {
+			return strings.Join(route, ".") + ":" + fmt.Sprint(value), nil
+		}},
+		testDirective{name: "summary", expand: func(ctx *DirectiveContext, value interface{}, route []string) (interface{}, error) {
+			g, exist, err := ctx.Parse(value.(string))
+			if err != nil || !exist {
+				return nil, fmt.Errorf("can't parse %v: %v", value, err)
+			}
+			return map[string]interface{}{"summary": g.Summary}, nil
+		}},
+		testDirective{name: "drop", expand: func(ctx *DirectiveContext, value interface{}, route []string) (interface{}, error) {
+			return nil, nil
+		}},
+		testDirective{name: "fail", expand: func(ctx *DirectiveContext, value interface{}, route []string) (interface{}, error) {
+			return nil, errors.New("no build info")
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = openAPi.RegisterDirective(testDirective{name: "build"})
+	if err == nil || !strings.Contains(err.Error(), "'x-$build' is already registered") {
+		t.Fatalf("expect duplicate error, got %v", err)
+	}
+
+	out, err := openAPi.CompleteYaml(`
+openapi: 3.0.1
+info:
+  title: t
+  x-$build: {}
+  x-$drop: 1
+paths:
+  /pets:
+    get:
+      x-$summary: github.com/gopenapi/gopenapi/internal/model.Pet
+      x-$routes: a
+`, Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), "")
+	for _, expect := range []string{
+		`"info":{"title":"t","version":"1.2.3","x-key":"x-$build","x-openapi":"3.0.1"}`,
+		`"get":{"summary":"Petispetmodel","x-$routes":"paths./pets.get:a"}`,
+	} {
+		if !strings.Contains(out, expect) {
+			t.Fatalf("expect %s in %s", expect, out)
+		}
+	}
+
+	_, err = openAPi.CompleteYaml(`
+paths:
+  /pets:
+    x-$fail: 1
+`, Json)
+	if err == nil || !strings.Contains(err.Error(), "run directive 'x-$fail' err: no build info") ||
+		!strings.Contains(err.Error(), "yaml: paths./pets") {
+		t.Fatalf("unexpected err: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package openapi
+
+import (
+	"gopkg.in/yaml.v2"
+	"sort"
+	"strings"
+)
+
+// 只读/只写变体的后缀, 在 gopenapi.conf.js 中开启 readWriteVariants 后,
+// 响应使用 PetRead(没有只写的字段), 请求体使用 PetWrite(没有只读的字段).
+const (
+	readSuffix  = "Read"
+	writeSuffix = "Write"
+)
+
+// variantDrops 是每种变体需要删除的字段的关键字
+var variantDrops = map[string]string{
+	readSuffix:  "writeOnly",
+	writeSuffix: "readOnly",
+}
+
+// completeReadWriteVariants 根据 components.schemas 中的 Pet 生成被引用的 PetRead 与 PetWrite.
+// 变体中引用的其他含有只读/只写字段的component也会使用对应的变体, 所以需要重复处理直到没有新的引用.
+func (o *OpenApi) completeReadWriteVariants(kv []yaml.MapItem) []yaml.MapItem {
+	if !o.readWriteVariants {
+		return kv
+	}
+
+	schemas := componentSchemas(kv)
+	refs := map[string]bool{}
+	collectRefs(kv, refs)
+
+	var variants []yaml.MapItem
+	added := map[string]bool{}
+	for {
+		var news []yaml.MapItem
+		for _, ref := range sortedKeys(refs) {
+			name := strings.TrimPrefix(ref, "#/"+schemasPrefix)
+			if name == ref || added[name] {
+				continue
+			}
+			if _, exist := schemas[name]; exist {
+				continue
+			}
+			for _, suffix := range []string{readSuffix, writeSuffix} {
+				s, exist := schemas[strings.TrimSuffix(name, suffix)]
+				if !strings.HasSuffix(name, suffix) || !exist {
+					continue
+				}
+				added[name] = true
+				news = append(news, yaml.MapItem{Key: name, Value: variantSchema(s, suffix, schemas)})
+			}
+		}
+		if len(news) == 0 {
+			break
+		}
+		collectRefs(news, refs)
+		variants = append(variants, news...)
+	}
+
+	sort.SliceStable(variants, func(i, j int) bool {
+		return variants[i].Key.(string) < variants[j].Key.(string)
+	})
+
+	return appendSchemas(kv, variants)
+}
+
+// componentSchemas 返回 components.schemas 中的所有schema, name => schema
+func componentSchemas(kv []yaml.MapItem) map[string]interface{} {
+	r := map[string]interface{}{}
+	for _, item := range kv {
+		if item.Key != "components" {
+			continue
+		}
+		components, _ := item.Value.([]yaml.MapItem)
+		for _, c := range components {
+			if c.Key != "schemas" {
+				continue
+			}
+			schemas, _ := c.Value.([]yaml.MapItem)
+			for _, s := range schemas {
+				if name, ok := s.Key.(string); ok {
+					r[name] = s.Value
+				}
+			}
+		}
+	}
+	return r
+}
+
+// variantSchema 复制schema, 并删除变体中不需要的字段, 引用的含有只读/只写字段的component会替换为对应的变体.
+func variantSchema(i interface{}, suffix string, schemas map[string]interface{}) interface{} {
+	drop := variantDrops[suffix]
+
+	switch i := i.(type) {
+	case []yaml.MapItem:
+		// 被删除的字段
+		dropped := map[string]bool{}
+		for _, item := range i {
+			if item.Key != "properties" {
+				continue
+			}
+			props, _ := item.Value.([]yaml.MapItem)
+			for _, p := range props {
+				if hasKeyword(p.Value, drop) {
+					dropped[p.Key.(string)] = true
+				}
+			}
+		}
+
+		var r []yaml.MapItem
+		for _, item := range i {
+			switch item.Key {
+			case "$ref":
+				ref, _ := item.Value.(string)
+				if hasReadWrite(schemas[strings.TrimPrefix(ref, "#/"+schemasPrefix)]) {
+					ref += suffix
+				}
+				r = append(r, yaml.MapItem{Key: item.Key, Value: ref})
+			case "properties":
+				props, _ := item.Value.([]yaml.MapItem)
+				var ps []yaml.MapItem
+				for _, p := range props {
+					if !dropped[p.Key.(string)] {
+						ps = append(ps, yaml.MapItem{Key: p.Key, Value: variantSchema(p.Value, suffix, schemas)})
+					}
+				}
+				r = append(r, yaml.MapItem{Key: item.Key, Value: ps})
+			case "required":
+				required, _ := item.Value.([]interface{})
+				var rs []interface{}
+				for _, name := range required {
+					if n, ok := name.(string); !ok || !dropped[n] {
+						rs = append(rs, name)
+					}
+				}
+				if len(rs) != 0 {
+					r = append(r, yaml.MapItem{Key: item.Key, Value: rs})
+				}
+			default:
+				r = append(r, yaml.MapItem{Key: item.Key, Value: variantSchema(item.Value, suffix, schemas)})
+			}
+		}
+		return r
+	case []interface{}:
+		r := make([]interface{}, len(i))
+		for j, item := range i {
+			r[j] = variantSchema(item, suffix, schemas)
+		}
+		return r
+	}
+	return i
+}
+
+// hasReadWrite 返回schema中是否有只读或只写的字段, allOf中内联的对象也算, 但不包括引用的component.
+func hasReadWrite(i interface{}) bool {
+	s, ok := i.([]yaml.MapItem)
+	if !ok {
+		return false
+	}
+	for _, item := range s {
+		switch item.Key {
+		case "properties":
+			props, _ := item.Value.([]yaml.MapItem)
+			for _, p := range props {
+				if hasKeyword(p.Value, "readOnly") || hasKeyword(p.Value, "writeOnly") {
+					return true
+				}
+			}
+		case "allOf":
+			items, _ := item.Value.([]interface{})
+			for _, a := range items {
+				if hasReadWrite(a) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// hasKeyword 返回schema中的关键字(e.g. readOnly)是否为true
+func hasKeyword(i interface{}, keyword string) bool {
+	s, ok := i.([]yaml.MapItem)
+	if !ok {
+		return false
+	}
+	for _, item := range s {
+		if item.Key == keyword {
+			return item.Value == true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]bool) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
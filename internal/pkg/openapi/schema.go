@@ -7,7 +7,9 @@ import (
 	"github.com/gopenapi/gopenapi/internal/pkg/jsonordered"
 	"github.com/gopenapi/gopenapi/internal/pkg/log"
 	"go/ast"
+	"go/token"
 	"sort"
+	"strconv"
 )
 
 type Schema interface {
@@ -21,16 +23,27 @@ var _ Schema = &ArraySchema{}
 var _ Schema = &AllOfSchema{}
 var _ Schema = &AnySchema{}
 var _ Schema = &IdentSchema{}
+var _ Schema = &RawSchema{}
+var _ Schema = &MapSchema{}
+var _ Schema = &OneOfSchema{}
+var _ Schema = &RefSchema{}
 
 type ObjectSchema struct {
 	Ref         string               `json:"$ref,omitempty"`
 	Type        string               `json:"type"`
 	Description string               `json:"description,omitempty"`
 	Properties  jsonordered.MapSlice `json:"properties"`
-	Example     interface{}          `json:"example,omitempty"`
+	// Required 是必填字段的json名字, 来自 binding/validate tag 中的 required 规则
+	Required []string    `json:"required,omitempty"`
+	Nullable bool        `json:"nullable,omitempty"`
+	Example  interface{} `json:"example,omitempty"`
 
-	Modify   []Modify `json:"modify,omitempty"`
-	IsSchema bool     `json:"x-schema,omitempty"`
+	IsSchema bool `json:"x-schema,omitempty"`
+	// IsWrapped 表示schema是 wrap() 返回的信封, 不会再被自动包装
+	IsWrapped bool `json:"x-wrapped,omitempty"`
+
+	// 用于修改schema的方法中解析参数, 见 modifiers
+	openapi *OpenApi
 }
 
 func (o *ObjectSchema) setRef(ref string) Schema {
@@ -38,18 +51,6 @@ func (o *ObjectSchema) setRef(ref string) Schema {
 	return o
 }
 
-// 实现装饰器语法
-// schema(model.x).require('id','name', any)
-func (o *ObjectSchema) GetMember(k string) (interface{}, error) {
-	return func(args ...interface{}) (interface{}, error) {
-		o.Modify = append(o.Modify, Modify{
-			Key:  k,
-			Args: args,
-		})
-		return o, nil
-	}, nil
-}
-
 func (o *ObjectSchema) _schema() {}
 
 type ArraySchema struct {
@@ -57,7 +58,11 @@ type ArraySchema struct {
 	Type        string `json:"type"`
 	Description string `json:"description,omitempty"`
 	Items       Schema `json:"items"`
-	IsSchema    bool   `json:"x-schema"`
+	// 固定长度的数组, 或者有校验规则时才有这两个值
+	MinItems *int `json:"minItems,omitempty"`
+	MaxItems *int `json:"maxItems,omitempty"`
+	Nullable bool `json:"nullable,omitempty"`
+	IsSchema bool `json:"x-schema"`
 }
 
 func (a *ArraySchema) setRef(ref string) Schema {
@@ -67,16 +72,39 @@ func (a *ArraySchema) setRef(ref string) Schema {
 
 func (a *ArraySchema) _schema() {}
 
-//type RefSchema struct {
-//	Ref      string `json:"$ref"`
-//	IsSchema bool   `json:"x-schema"`
-//}
+// MapSchema 对应go中的map类型
+type MapSchema struct {
+	Ref                  string `json:"$ref,omitempty"`
+	Type                 string `json:"type"`
+	Description          string `json:"description,omitempty"`
+	AdditionalProperties Schema `json:"additionalProperties"`
+	MinProperties        *int   `json:"minProperties,omitempty"`
+	MaxProperties        *int   `json:"maxProperties,omitempty"`
+	Nullable             bool   `json:"nullable,omitempty"`
+	IsSchema             bool   `json:"x-schema"`
+}
 
-type Modify struct {
-	Key  string
-	Args []interface{}
+func (m *MapSchema) setRef(ref string) Schema {
+	m.Ref = ref
+	return m
 }
 
+func (m *MapSchema) _schema() {}
+
+// RefSchema 只有$ref, 用于递归类型中对自身的引用
+type RefSchema struct {
+	Ref      string `json:"$ref"`
+	Nullable bool   `json:"nullable,omitempty"`
+	IsSchema bool   `json:"x-schema"`
+}
+
+func (r *RefSchema) setRef(ref string) Schema {
+	r.Ref = ref
+	return r
+}
+
+func (r *RefSchema) _schema() {}
+
 //func (r *RefSchema) _schema() {}
 
 // 基础类型, string / int
@@ -84,10 +112,23 @@ type IdentSchema struct {
 	Ref string `json:"$ref,omitempty"`
 
 	Type        string        `json:"type"`
+	Format      string        `json:"format,omitempty"`
 	Description string        `json:"description,omitempty"`
 	Default     interface{}   `json:"default,omitempty"`
 	Enum        []interface{} `json:"enum,omitempty"`
-	IsSchema    bool          `json:"x-schema,omitempty"`
+	// 枚举值对应的常量名与注释, 用于代码生成工具, e.g. openapi-generator
+	EnumVarNames     []string `json:"x-enum-varnames,omitempty"`
+	EnumDescriptions []string `json:"x-enum-descriptions,omitempty"`
+	Nullable         bool     `json:"nullable,omitempty"`
+	IsSchema         bool     `json:"x-schema,omitempty"`
+
+	// 校验规则, 来自 binding/validate tag
+	Minimum          *float64 `json:"minimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMinimum bool     `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum bool     `json:"exclusiveMaximum,omitempty"`
+	MinLength        *int     `json:"minLength,omitempty"`
+	MaxLength        *int     `json:"maxLength,omitempty"`
 
 	Example interface{} `json:"example,omitempty"`
 }
@@ -117,20 +158,64 @@ func (n ErrSchema) _schema() {
 }
 
 type AnySchema struct {
+	Ref         string        `json:"$ref,omitempty"`
 	IsSchema    bool          `json:"x-schema,omitempty"`
 	IsAny       bool          `json:"x-any,omitempty"`
 	Description string        `json:"description,omitempty"`
 	OneOf       []interface{} `json:"oneOf"`
 }
 
+// newAnySchema 返回允许任意类型的schema
+func newAnySchema(description string) *AnySchema {
+	return &AnySchema{
+		IsSchema:    true,
+		IsAny:       true,
+		Description: description,
+		OneOf: []interface{}{
+			map[string]interface{}{"type": "array"},
+			map[string]interface{}{"type": "boolean"},
+			map[string]interface{}{"type": "integer"},
+			map[string]interface{}{"type": "number"},
+			map[string]interface{}{"type": "object"},
+			map[string]interface{}{"type": "string"},
+		},
+	}
+}
+
 func (n AnySchema) setRef(ref string) Schema {
-	panic("implement me")
+	n.Ref = ref
+	return n
 }
 
 func (n AnySchema) _schema() {
 }
 
+// OneOfSchema 对应go中的接口, 成员是接口的实现
+type OneOfSchema struct {
+	Ref           string         `json:"$ref,omitempty"`
+	Description   string         `json:"description,omitempty"`
+	OneOf         []Schema       `json:"oneOf"`
+	Discriminator *Discriminator `json:"discriminator,omitempty"`
+	Nullable      bool           `json:"nullable,omitempty"`
+	IsSchema      bool           `json:"x-schema"`
+}
+
+// Discriminator 用于区分oneOf中的成员
+// mapping: 字段值 => $ref
+type Discriminator struct {
+	PropertyName string               `json:"propertyName"`
+	Mapping      jsonordered.MapSlice `json:"mapping,omitempty"`
+}
+
+func (s *OneOfSchema) setRef(ref string) Schema {
+	s.Ref = ref
+	return s
+}
+
+func (s *OneOfSchema) _schema() {}
+
 type AllOfSchema struct {
+	Ref   string   `json:"$ref,omitempty"`
 	AllOf []Schema `json:"allOf"`
 
 	// AllOf 也有Properties字段, 这是为了在js中使用此字段转为params数组
@@ -142,7 +227,8 @@ func (n AllOfSchema) _schema() {
 }
 
 func (n AllOfSchema) setRef(ref string) Schema {
-	panic("implement me")
+	n.Ref = ref
+	return n
 }
 
 // ObjectProp 对象的成员
@@ -150,6 +236,8 @@ type ObjectProp struct {
 	Schema Schema               `json:"schema"`
 	Meta   jsonordered.MapSlice `json:"meta,omitempty"`
 	Tag    map[string]string    `json:"tag,omitempty"`
+	// Required 表示字段是必填的, 用于在js中生成params
+	Required bool `json:"required,omitempty"`
 }
 
 //type Expr struct {
@@ -178,11 +266,11 @@ func (expr *GoExprWithPath) Key() (string, error) {
 //   exprInFile 是这个expr在哪一个文件中(必须是相对路径, 如github.com/gopenapi/gopenapi/internal/model/pet.go), 这是为了识别到这个文件引入了哪些包.
 func (o *OpenApi) goAstToSchema(expr *GoExprWithPath) (Schema, error) {
 	ga := GoAstToSchema{
-		goparse:           o.goparse,
-		parsedSchemas:     o.schemas,
-		schemasDef:        o.schemasDef,
-		parsedSchemaCount: map[string]int{},
-		openapi:           o,
+		goparse:       o.goparse,
+		parsedSchemas: o.schemas,
+		schemasDef:    o.schemasDef,
+		parsing:       map[string]bool{},
+		openapi:       o,
 	}
 
 	return ga.goAstToSchema(expr)
@@ -202,7 +290,15 @@ func (o *GoAstToSchema) goAstToSchema(goExpr *GoExprWithPath) (rs Schema, err er
 
 		// 只要在schema定义过, 则都会生成ref, 在js端的时候可以选择是否忽略ref
 		defer func() {
-			if yamlKey, ok := o.schemasDef[k]; ok {
+			if rs == nil {
+				return
+			}
+			yamlKey, ok := o.schemasDef[k]
+			if !ok {
+				// 没有定义的类型, 如果开启了 x-$autoComponents 则自动注册
+				yamlKey, ok = o.openapi.autoComponent(k, rs)
+			}
+			if ok {
 				rs = rs.setRef("#/" + yamlKey)
 			}
 		}()
@@ -218,70 +314,200 @@ func (o *GoAstToSchema) goAstToSchema(goExpr *GoExprWithPath) (rs Schema, err er
 			return ref, nil
 		}
 
-		// 可以递归两次，超出则报错
-		if count, ok := o.parsedSchemaCount[k]; ok && count >= 2 {
-			msg := fmt.Sprintf("recursive references on '%s'", k)
-			var s Schema
-			s = &ErrSchema{IsSchema: true, XError: msg}
-			return s, nil
+		// 类型映射, e.g. time.Time 直接映射为 {type: string, format: date-time}
+		mapped, exist, err := o.mappedSchema(k)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			return mapped, nil
 		}
 
-		o.parsedSchemaCount[k] ++
+		// 递归的类型, 引用它自身的component, 没有定义component时会自动注册
+		if o.parsing[k] {
+			yamlKey, ok := o.schemasDef[k]
+			if !ok {
+				yamlKey, ok = o.openapi.recursiveComponent(k)
+			}
+			if !ok {
+				msg := fmt.Sprintf("recursive references on '%s'", k)
+				return &ErrSchema{IsSchema: true, XError: msg}, nil
+			}
+			return &RefSchema{Ref: "#/" + yamlKey, IsSchema: true}, nil
+		}
+
+		o.parsing[k] = true
+		defer delete(o.parsing, k)
 
 	}
 
 	switch expr := goExpr.expr.(type) {
 	case *ast.ArrayType:
+		// []byte 会被json序列化为base64字符串
+		if elt, ok := expr.Elt.(*ast.Ident); ok && expr.Len == nil && (elt.Name == "byte" || elt.Name == "uint8") {
+			gd, err := o.openapi.parseGoDoc(goExpr.doc.Text(), goExpr.file)
+			if err != nil {
+				return nil, err
+			}
+			return &IdentSchema{
+				Type:        "string",
+				Format:      "byte",
+				IsSchema:    true,
+				Description: gd.FullDoc,
+			}, nil
+		}
+
 		schema, err := o.goAstToSchema(&GoExprWithPath{
-			goparse: o.goparse,
-			openapi: o.openapi,
-			expr:    expr.Elt,
-			doc:     goExpr.doc,
-			file:    goExpr.file,
-			name:    "",
-			key:     "",
+			goparse:  o.goparse,
+			openapi:  o.openapi,
+			expr:     expr.Elt,
+			doc:      goExpr.doc,
+			file:     goExpr.file,
+			name:     "",
+			key:      "",
+			typeArgs: goExpr.typeArgs,
 		})
 		if err != nil {
 			return nil, err
 		}
+		if schema == nil {
+			// 如 []func(), 与 func 字段一样忽略
+			return nil, nil
+		}
 		gd, err := o.openapi.parseGoDoc(goExpr.doc.Text(), goExpr.file)
 		if err != nil {
 			return nil, err
 		}
-		schema = &ArraySchema{
+		arr := &ArraySchema{
 			Type:        "array",
 			Items:       schema,
 			IsSchema:    true,
 			Description: gd.FullDoc,
 		}
-		return schema, nil
-	case *ast.StarExpr:
+		// 固定长度的数组, e.g. [3]int
+		if expr.Len != nil {
+			if l, ok := arrayLen(expr.Len); ok {
+				arr.MinItems = &l
+				arr.MaxItems = &l
+			}
+		}
+		return arr, nil
+	case *ast.MapType:
+		// map[string]T 转为 additionalProperties
+		// encoding/json 会将所有类型的key都序列化为字符串, 所以不需要关心key的类型
+		valueSchema, err := o.goAstToSchema(&GoExprWithPath{
+			goparse:  o.goparse,
+			openapi:  o.openapi,
+			expr:     expr.Value,
+			doc:      nil,
+			file:     goExpr.file,
+			name:     "",
+			key:      "",
+			typeArgs: goExpr.typeArgs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if valueSchema == nil {
+			return nil, nil
+		}
+		gd, err := o.openapi.parseGoDoc(goExpr.doc.Text(), goExpr.file)
+		if err != nil {
+			return nil, err
+		}
+		return &MapSchema{
+			Type:                 "object",
+			Description:          gd.FullDoc,
+			AdditionalProperties: valueSchema,
+			IsSchema:             true,
+		}, nil
+	case *ast.ParenExpr:
 		return o.goAstToSchema(&GoExprWithPath{
-			openapi: o.openapi,
-			goparse: goExpr.goparse,
-			expr:    expr.X,
-			doc:     goExpr.doc,
-			file:    goExpr.file,
-			name:    goExpr.name,
-			key:     goExpr.key,
+			openapi:  o.openapi,
+			goparse:  goExpr.goparse,
+			expr:     expr.X,
+			doc:      goExpr.doc,
+			file:     goExpr.file,
+			name:     goExpr.name,
+			key:      goExpr.key,
+			typeArgs: goExpr.typeArgs,
+		})
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		// 泛型实例化, e.g. Page[Pet], model.Page[model.Pet]
+		return o.instantiate(goExpr)
+	case *ast.FuncType, *ast.ChanType:
+		// 和 encoding/json 一样, 不能被序列化的类型都忽略
+		return nil, nil
+	case *ast.StarExpr:
+		schema, err := o.goAstToSchema(&GoExprWithPath{
+			openapi:  o.openapi,
+			goparse:  goExpr.goparse,
+			expr:     expr.X,
+			doc:      goExpr.doc,
+			file:     goExpr.file,
+			name:     goExpr.name,
+			key:      goExpr.key,
+			typeArgs: goExpr.typeArgs,
 		})
+		if err != nil {
+			return nil, err
+		}
+		// 指针可能是nil
+		setNullable(schema)
+		return schema, nil
 	case *ast.Ident:
+		// 泛型的类型参数, 替换为实参
+		if arg, ok := goExpr.typeArgs[expr.Name]; ok {
+			arg := *arg
+			if goExpr.doc != nil {
+				arg.doc = goExpr.doc
+			}
+			if arg.goparse == nil {
+				// 缺少的实参, 见 newTypeArgs
+				arg.goparse, arg.openapi, arg.file = o.goparse, o.openapi, goExpr.file
+			}
+			return o.goAstToSchema(&arg)
+		}
+
 		// 标识
 		// 如果是基础类型, 则返回, 否则还需要继续递归.
-		if is, t := IsBaseType(expr.Name); is {
+		if bt, is := baseTypes[expr.Name]; is {
 			gd, err := o.openapi.parseGoDoc(goExpr.doc.Text(), goExpr.file)
 			if err != nil {
 				return nil, err
 			}
 
-			return &IdentSchema{
-				Type:        t,
+			s := &IdentSchema{
+				Type:        bt.Type,
+				Format:      bt.Format,
 				Default:     nil,
 				Enum:        nil,
 				IsSchema:    true,
 				Description: gd.FullDoc,
 				Example:     nil,
-			}, nil
+			}
+			if bt.Minimum != nil {
+				s.Minimum = floatPtr(*bt.Minimum)
+			}
+			if bt.Maximum != nil {
+				s.Maximum = floatPtr(*bt.Maximum)
+			}
+			return s, nil
+		}
+		if isComplexType(expr.Name) {
+			// 复数不能被json序列化, 和func一样忽略
+			log.Warningf("error at %s : type '%s' can't be encoded to json, it is omitted", goExpr.file, expr.Name)
+			return nil, nil
+		}
+		if expr.Name == "any" {
+			// 预定义的 any 与 interface{} 相同, 允许任意类型
+			return o.goAstToSchema(&GoExprWithPath{
+				openapi: o.openapi,
+				goparse: o.goparse,
+				expr:    emptyInterface(),
+				doc:     goExpr.doc,
+				file:    goExpr.file,
+			})
 		}
 		def, exist, err := o.goparse.GetDef(o.goparse.GetPkgOfFile(goExpr.file), expr.Name)
 		// 获取当前包下的结构体
@@ -301,6 +527,11 @@ func (o *GoAstToSchema) goAstToSchema(goExpr *GoExprWithPath) (rs Schema, err er
 		if doc == nil {
 			doc = def.Doc
 		}
+		key := def.Key
+		if def.IsAlias {
+			// 别名(type A = B)与原类型是同一个类型, 使用原类型的标识
+			key = ""
+		}
 		schema, err := o.goAstToSchema(&GoExprWithPath{
 			openapi: o.openapi,
 			goparse: o.goparse,
@@ -308,7 +539,7 @@ func (o *GoAstToSchema) goAstToSchema(goExpr *GoExprWithPath) (rs Schema, err er
 			doc:     doc,
 			file:    def.File,
 			name:    "",
-			key:     def.Key,
+			key:     key,
 		})
 		if err != nil {
 			return nil, err
@@ -323,10 +554,21 @@ func (o *GoAstToSchema) goAstToSchema(goExpr *GoExprWithPath) (rs Schema, err er
 				return nil, err
 			}
 
-			_, defValue := enum.FirstValue()
-
-			idt.Enum = enum.Values
-			idt.Default = defValue
+			// 没有枚举值时保留子级的枚举, e.g. 别名或者 type A B
+			if len(enum.Values) != 0 {
+				_, defValue := enum.FirstValue()
+
+				idt.Enum = enum.Values
+				idt.Default = defValue
+				idt.EnumVarNames = enum.Keys
+				idt.EnumDescriptions = nil
+				for _, d := range enum.Docs {
+					if d != "" {
+						idt.EnumDescriptions = enum.Docs
+						break
+					}
+				}
+			}
 		}
 
 		return schema, err
@@ -366,46 +608,75 @@ func (o *GoAstToSchema) goAstToSchema(goExpr *GoExprWithPath) (rs Schema, err er
 		return &ErrSchema{}, nil
 	case *ast.StructType:
 		var props jsonordered.MapSlice
+		var required []string
 
 		allOf := AllOfSchema{
 			AllOf:    nil,
 			IsSchema: true,
 		}
 		for _, f := range expr.Fields.List {
+			jt := parseJsonTag(f.Tag)
+			// 和 encoding/json 一样, 忽略 json:"-" 的字段
+			if jt.Skip {
+				continue
+			}
+
+			// 和 encoding/json 一样, 忽略未导出的字段
+			var names []string
+			for _, n := range f.Names {
+				if ast.IsExported(n.Name) {
+					names = append(names, n.Name)
+				}
+			}
+			if len(f.Names) != 0 && len(names) == 0 {
+				continue
+			}
+
+			fieldType := f.Type
+			if star, ok := fieldType.(*ast.StarExpr); ok && len(f.Names) == 0 {
+				// 嵌套的指针结构体与结构体的json格式是一样的
+				fieldType = star.X
+			}
+
 			fieldSchema, err := o.goAstToSchema(&GoExprWithPath{
 				openapi: o.openapi,
 				goparse: o.goparse,
-				expr:    f.Type,
+				expr:    fieldType,
 				file:    goExpr.file,
 				name:    "",
 				//name:    name,
-				key: "",
-				doc: f.Doc,
+				key:      "",
+				doc:      f.Doc,
+				typeArgs: goExpr.typeArgs,
 			})
 			if err != nil {
 				return nil, err
 			}
+			if fieldSchema == nil {
+				// 不能被序列化的字段, 如 func 与 chan
+				continue
+			}
 
-			var name string
 			// nested
 			// 组合（嵌套）格式处理
-			if len(f.Names) != 0 {
-				name = f.Names[0].Name
-			} else if f.Tag != nil {
-				name = getExprName(f.Type)
-			} else {
-				// 对于golang的组合语法, 都使用allOf语法实现
-				//
-				// 当是嵌套, 并且没有任何tag时, 才展开子级
-				// 如果字段schema是refSchema，则使用allOf语法
-				// 如果字段是ObjectSchema，则展开
-				// 如果不是上面则情况，则当成普通字段处理
-				allOf.AllOf = append(allOf.AllOf, fieldSchema)
-				switch t := fieldSchema.(type) {
-				case *ObjectSchema:
-					allOf.Properties = append(allOf.Properties, t.Properties...)
+			if len(f.Names) == 0 {
+				if jt.Name != "" {
+					// 和 encoding/json 一样, 在json tag中指定了名字的嵌套结构体会被当做普通字段
+					names = []string{getExprName(f.Type)}
+				} else {
+					// 对于golang的组合语法, 都使用allOf语法实现
+					//
+					// 当是嵌套, 并且json tag中没有指定名字时, 才展开子级
+					// 如果字段schema是refSchema，则使用allOf语法
+					// 如果字段是ObjectSchema，则展开
+					// 如果不是上面则情况，则当成普通字段处理
+					allOf.AllOf = append(allOf.AllOf, fieldSchema)
+					switch t := fieldSchema.(type) {
+					case *ObjectSchema:
+						allOf.Properties = append(allOf.Properties, t.Properties...)
+					}
+					continue
 				}
-				continue
 			}
 
 			gd, err := o.openapi.parseGoDoc(f.Doc.Text(), goExpr.file)
@@ -413,14 +684,31 @@ func (o *GoAstToSchema) goAstToSchema(goExpr *GoExprWithPath) (rs Schema, err er
 				return nil, err
 			}
 
-			props = append(props, jsonordered.MapItem{
-				Key: name,
-				Val: ObjectProp{
-					Schema: fieldSchema,
-					Meta:   gd.Meta,
-					Tag:    encodeTag(f.Tag),
-				},
-			})
+			if jt.AsString {
+				// 与校验规则一样, 修改副本, 不影响共享的schema
+				fieldSchema = cloneSchema(fieldSchema)
+				applyJsonString(fieldSchema)
+			}
+
+			// binding/validate tag 中的校验规则
+			fieldSchema, isRequired := applyValidateTag(fieldSchema, f.Tag)
+
+			for _, name := range names {
+				// 没有omitempty的字段总会被序列化, 所以也是必须的
+				if isRequired || (o.openapi.jsonRequired && !jt.OmitEmpty) {
+					required = append(required, jt.jsonName(name))
+				}
+
+				props = append(props, jsonordered.MapItem{
+					Key: name,
+					Val: ObjectProp{
+						Schema:   fieldSchema,
+						Meta:     gd.Meta,
+						Tag:      encodeTag(f.Tag),
+						Required: isRequired,
+					},
+				})
+			}
 		}
 		gd, err := o.openapi.parseGoDoc(goExpr.doc.Text(), goExpr.file)
 		if err != nil {
@@ -429,10 +717,11 @@ func (o *GoAstToSchema) goAstToSchema(goExpr *GoExprWithPath) (rs Schema, err er
 		var schema Schema = &ObjectSchema{
 			Type:        "object",
 			Properties:  props,
+			Required:    required,
 			IsSchema:    true,
 			Description: gd.FullDoc,
 			Example:     nil,
-			Modify:      nil,
+			openapi:     o.openapi,
 		}
 
 		if len(allOf.AllOf) != 0 {
@@ -448,24 +737,55 @@ func (o *GoAstToSchema) goAstToSchema(goExpr *GoExprWithPath) (rs Schema, err er
 			return nil, err
 		}
 
-		return &AnySchema{
-			IsSchema:    true,
-			IsAny:       true,
-			Description: gd.FullDoc,
-			OneOf: []interface{}{
-				map[string]interface{}{"type": "array"},
-				map[string]interface{}{"type": "boolean"},
-				map[string]interface{}{"type": "integer"},
-				map[string]interface{}{"type": "number"},
-				map[string]interface{}{"type": "object"},
-				map[string]interface{}{"type": "string"},
-			},
-		}, nil
+		// 有实现的接口转为oneOf, 否则允许任意类型
+		schema, err := o.interfaceToOneOf(goExpr, expr)
+		if err != nil {
+			return nil, err
+		}
+		if schema == nil {
+			return newAnySchema(gd.FullDoc), nil
+		}
+		schema.Description = gd.FullDoc
+		return schema, nil
 	default:
 		panic(fmt.Sprintf("uncased goAstToSchema type: %T, %+v", expr, expr))
 	}
 }
 
+// setNullable 将schema标记为可以为null, 用于指针类型.
+// 在openapi 3.1中会在gopenapi.conf.js中转为 type: [x, 'null']
+func setNullable(s Schema) {
+	switch s := s.(type) {
+	case *IdentSchema:
+		s.Nullable = true
+	case *ObjectSchema:
+		s.Nullable = true
+	case *ArraySchema:
+		s.Nullable = true
+	case *MapSchema:
+		s.Nullable = true
+	case *OneOfSchema:
+		s.Nullable = true
+	case *RefSchema:
+		s.Nullable = true
+	case *RawSchema:
+		s.Nullable = true
+	}
+}
+
+// arrayLen 返回数组的长度, 只支持字面量, e.g. [3]int
+func arrayLen(e ast.Expr) (int, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.INT {
+		return 0, false
+	}
+	l, err := strconv.Atoi(lit.Value)
+	if err != nil {
+		return 0, false
+	}
+	return l, true
+}
+
 // getExprName返回表达式在嵌套语法中的字段名
 // e.g.
 // - model.Category 返回 Category
@@ -477,6 +797,10 @@ func getExprName(e ast.Expr) string {
 		return getExprName(t.X)
 	case *ast.SelectorExpr:
 		return getExprName(t.Sel)
+	case *ast.IndexExpr:
+		return getExprName(t.X)
+	case *ast.IndexListExpr:
+		return getExprName(t.X)
 	default:
 		panic(fmt.Sprintf("uncased type '%T' for getExprName", e))
 	}
@@ -507,6 +831,11 @@ type GoExprWithPath struct {
 	// 当前表达式的唯一标识, 如 github.com/gopenapi/gopenapi/internal/delivery/http/handler.PetHandler.FindPetByStatus
 	// 此值有可能为空, 如 表达式是具体的某个结构体声明时无法获得key.
 	key string
+
+	// typeArgs 是泛型实例化后, 类型参数对应的实参. e.g. Page[model.Pet] 中 T => model.Pet
+	typeArgs typeArgs
+	// typeParams 是泛型类型的类型参数, 用于在js中实例化泛型, e.g. model.Page[model.Pet]
+	typeParams []string
 }
 
 // 在解析成json时(在js脚本中使用), 需要解析成js脚本能使用的格式, 即 GoStruct
@@ -582,9 +911,9 @@ type GoAstToSchema struct {
 	// go def path => yaml key route
 	schemasDef map[string]string
 
-	// 已经解析过的schema次数, 防止无限递归
-	// 和 parsedSchemas 不同的是, parsedSchemaCount 只计数, 每次遇到相同的key就计数, 而不是等到函数return, 这样才能识别递归.
-	parsedSchemaCount map[string]int
+	// 正在解析的类型, 即当前解析路径上的类型, 用于识别递归.
+	// 和 parsedSchemas 不同的是, 开始解析时就会记录, 返回时删除, 所以同一个类型出现在多个字段中不是递归.
+	parsing map[string]bool
 
 	openapi *OpenApi
 }
@@ -598,19 +927,7 @@ func (o *OpenApi) anyToSchema(i interface{}) (Schema, error) {
 		if len(s) == 0 {
 			return &ArraySchema{
 				Type: "array",
-				Items: &AnySchema{
-					IsSchema:    true,
-					IsAny:       true,
-					Description: "",
-					OneOf: []interface{}{
-						map[string]interface{}{"type": "array"},
-						map[string]interface{}{"type": "boolean"},
-						map[string]interface{}{"type": "integer"},
-						map[string]interface{}{"type": "number"},
-						map[string]interface{}{"type": "object"},
-						map[string]interface{}{"type": "string"},
-					},
-				},
+				Items: newAnySchema(""),
 				IsSchema: true,
 			}, nil
 		}
@@ -651,6 +968,7 @@ func (o *OpenApi) anyToSchema(i interface{}) (Schema, error) {
 			Type:       "object",
 			Properties: props,
 			IsSchema:   true,
+			openapi:    o,
 		}, nil
 	case string:
 		return &IdentSchema{
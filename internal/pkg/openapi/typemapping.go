@@ -0,0 +1,218 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gopenapi/gopenapi/internal/pkg/goast"
+	"github.com/gopenapi/gopenapi/internal/pkg/jsonordered"
+	"github.com/gopenapi/gopenapi/internal/pkg/log"
+	"strings"
+)
+
+// TypeMapping 将go类型直接映射为schema, 而不再解析类型的结构.
+// 用于那些序列化结果与结构体字段无关的类型, 如 time.Time 会被序列化为字符串.
+//
+// key 是类型的唯一标识, 即 {引入路径}.{类型名}, e.g. time.Time, github.com/shopspring/decimal.Decimal
+// value 是openapi schema, 为空对象时表示任意类型.
+type TypeMapping map[string]jsonordered.MapSlice
+
+// 内置的类型映射, 可以在 gopenapi.conf.js 中通过 typeMapping 覆盖或扩展
+var builtinTypeMapping = TypeMapping{
+	"time.Time": {
+		{Key: "type", Val: "string"},
+		{Key: "format", Val: "date-time"},
+	},
+	"time.Duration": {
+		{Key: "type", Val: "string"},
+	},
+	"encoding/json.RawMessage": {},
+	"database/sql.NullString": {
+		{Key: "type", Val: "string"},
+		{Key: "nullable", Val: true},
+	},
+	"database/sql.NullBool": {
+		{Key: "type", Val: "boolean"},
+		{Key: "nullable", Val: true},
+	},
+	"database/sql.NullByte": {
+		{Key: "type", Val: "integer"},
+		{Key: "nullable", Val: true},
+	},
+	"database/sql.NullInt16": {
+		{Key: "type", Val: "integer"},
+		{Key: "format", Val: "int32"},
+		{Key: "nullable", Val: true},
+	},
+	"database/sql.NullInt32": {
+		{Key: "type", Val: "integer"},
+		{Key: "format", Val: "int32"},
+		{Key: "nullable", Val: true},
+	},
+	"database/sql.NullInt64": {
+		{Key: "type", Val: "integer"},
+		{Key: "format", Val: "int64"},
+		{Key: "nullable", Val: true},
+	},
+	"database/sql.NullFloat64": {
+		{Key: "type", Val: "number"},
+		{Key: "format", Val: "double"},
+		{Key: "nullable", Val: true},
+	},
+	"database/sql.NullTime": {
+		{Key: "type", Val: "string"},
+		{Key: "format", Val: "date-time"},
+		{Key: "nullable", Val: true},
+	},
+}
+
+// newTypeMapping 合并内置的类型映射与用户配置的类型映射, 用户配置的优先.
+func newTypeMapping(custom TypeMapping) TypeMapping {
+	m := TypeMapping{}
+	for k, v := range builtinTypeMapping {
+		m[k] = v
+	}
+	for k, v := range custom {
+		m[k] = v
+	}
+	return m
+}
+
+// RawSchema 是由类型映射或者 $schema 注释直接给出的schema, 会原样输出.
+type RawSchema struct {
+	Ref         string
+	Description string
+	Schema      jsonordered.MapSlice
+	Nullable    bool
+}
+
+func (r *RawSchema) _schema() {}
+
+func (r *RawSchema) setRef(ref string) Schema {
+	r.Ref = ref
+	return r
+}
+
+func (r *RawSchema) MarshalJSON() ([]byte, error) {
+	var m jsonordered.MapSlice
+	if r.Ref != "" {
+		m = append(m, jsonordered.MapItem{Key: "$ref", Val: r.Ref})
+	}
+	m = append(m, r.Schema...)
+	if _, exist := r.Schema.Get("description"); !exist && r.Description != "" {
+		m = append(m, jsonordered.MapItem{Key: "description", Val: r.Description})
+	}
+	if _, exist := r.Schema.Get("nullable"); !exist && r.Nullable {
+		m = append(m, jsonordered.MapItem{Key: "nullable", Val: true})
+	}
+	m = append(m, jsonordered.MapItem{Key: "x-schema", Val: true})
+
+	return json.Marshal(m)
+}
+
+// newMappedSchema 将映射表中的值转为Schema, 空对象表示任意类型.
+func newMappedSchema(m jsonordered.MapSlice, description string) Schema {
+	if len(m) == 0 {
+		return newAnySchema(description)
+	}
+
+	return &RawSchema{
+		Description: description,
+		Schema:      m,
+	}
+}
+
+// toMapSlice 将js或yaml中写的对象转为有序的MapSlice
+func toMapSlice(i interface{}) (jsonordered.MapSlice, error) {
+	bs, err := json.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+	o, err := jsonordered.UnmarshalToOrderJson(bs)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := o.(jsonordered.MapSlice)
+	if !ok {
+		return nil, fmt.Errorf("schema must be an object, but got: %s", bs)
+	}
+	return m, nil
+}
+
+// mappedSchema 查找go类型对应的schema, 如果找到则不需要再解析类型的结构. 依次查找:
+// - 类型声明注释中的 $schema, e.g. `// $schema: {type: string, format: decimal}`
+// - gopenapi.conf.js 中的 typeMapping 以及内置的类型映射
+// - 实现了 MarshalJSON 或 MarshalText 的类型, 见 marshalerSchema
+// key: 类型的唯一标识, e.g. time.Time
+func (o *GoAstToSchema) mappedSchema(key string) (Schema, bool, error) {
+	pkg, name := splitPkgPath(key)
+	// 只处理类型, 不处理如 PetHandler.FindPetByStatus 的方法
+	isType := pkg != "" && name != "" && !strings.Contains(name, ".")
+
+	var def *goast.Def
+	if isType {
+		var exist bool
+		var err error
+		def, exist, err = o.goparse.GetDef(pkg, name)
+		if err != nil {
+			return nil, false, err
+		}
+		if !exist {
+			def = nil
+		}
+		if def != nil && strings.Contains(def.Doc.Text(), "$schema") {
+			gd, err := o.openapi.parseGoDoc(def.Doc.Text(), def.File)
+			if err != nil {
+				return nil, false, err
+			}
+			if s, exist := gd.Meta.Get("schema"); exist {
+				m, err := toMapSlice(s)
+				if err != nil {
+					return nil, false, fmt.Errorf("invalid $schema of '%s': %w", key, err)
+				}
+				return newMappedSchema(m, gd.FullDoc), true, nil
+			}
+		}
+	}
+
+	if m, exist := o.openapi.typeMapping[key]; exist {
+		return newMappedSchema(m, ""), true, nil
+	}
+
+	if def != nil {
+		return o.marshalerSchema(pkg, def)
+	}
+
+	return nil, false, nil
+}
+
+// marshalerSchema 处理自定义了json序列化的类型, 它们的json格式与结构体字段无关:
+// - 实现了 MarshalJSON 的类型无法得知格式, 需要在类型注释中写 $schema, 否则提示并当做任意类型
+// - 实现了 MarshalText 的类型会被序列化为字符串
+// 和 encoding/json 一样, MarshalJSON 优先于 MarshalText.
+func (o *GoAstToSchema) marshalerSchema(pkg string, def *goast.Def) (Schema, bool, error) {
+	funcs, err := o.goparse.GetFuncOfStruct(pkg, def.Name)
+	if err != nil {
+		return nil, false, err
+	}
+	_, isJson := funcs["MarshalJSON"]
+	_, isText := funcs["MarshalText"]
+	if !isJson && !isText {
+		return nil, false, nil
+	}
+
+	gd, err := o.openapi.parseGoDoc(def.Doc.Text(), def.File)
+	if err != nil {
+		return nil, false, err
+	}
+	if isJson {
+		log.Warningf("%s:%d: type '%s' implements MarshalJSON, its schema can't be derived from its fields, "+
+			"please add a '$schema' meta-comment to it, e.g. `// $schema: {type: string}`", def.File, def.Line, def.Name)
+		return newAnySchema(gd.FullDoc), true, nil
+	}
+
+	return &IdentSchema{
+		Type:        "string",
+		Description: gd.FullDoc,
+		IsSchema:    true,
+	}, true, nil
+}
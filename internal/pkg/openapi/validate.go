@@ -0,0 +1,250 @@
+package openapi
+
+import (
+	"go/ast"
+	"strconv"
+	"strings"
+)
+
+// 读取校验规则的tag, gin 使用 binding, go-playground/validator 使用 validate, 它们的语法是一样的.
+var validateTagKeys = []string{"binding", "validate"}
+
+// validate 中 email 等规则对应的 openapi format
+var validateFormats = map[string]string{
+	"email":    "email",
+	"url":      "uri",
+	"uri":      "uri",
+	"uuid":     "uuid",
+	"uuid3":    "uuid",
+	"uuid4":    "uuid",
+	"uuid5":    "uuid",
+	"ipv4":     "ipv4",
+	"ipv6":     "ipv6",
+	"hostname": "hostname",
+}
+
+// applyValidateTag 将字段tag中的校验规则(binding, validate)转为schema的约束, 返回新的schema与字段是否是必填的.
+// schema 可能是共享的(如 X, Y int 与缓存的类型), 所以不会修改它, 而是修改它的副本.
+// 支持以下规则:
+//   - required
+//   - min, max, len, gt, gte, lt, lte: 根据字段类型转为 minimum/maximum, minLength/maxLength, minItems/maxItems
+//   - oneof: 转为enum
+//   - email, uuid, url 等: 转为format
+//   - dive: 之后的规则作用于数组(或map)的成员
+// e.g. `binding:"required,min=1,max=100"`
+func applyValidateTag(schema Schema, tag *ast.BasicLit) (out Schema, required bool) {
+	out = schema
+	st := structTag(tag)
+	for _, key := range validateTagKeys {
+		rules, ok := st.Lookup(key)
+		if !ok {
+			continue
+		}
+
+		out = cloneSchema(out)
+		s := out
+		for _, rule := range strings.Split(rules, ",") {
+			// 不支持 or 语法, e.g. rgb|rgba
+			if strings.Contains(rule, "|") {
+				continue
+			}
+			name, param := rule, ""
+			if i := strings.IndexByte(rule, '='); i != -1 {
+				name, param = rule[:i], rule[i+1:]
+			}
+
+			switch name {
+			case "required":
+				// dive 之后的required表示成员不能为空, 而不是字段必填
+				if s == out {
+					required = true
+				}
+			case "dive":
+				elem := cloneSchema(elemSchema(s))
+				if elem == nil {
+					return
+				}
+				setElemSchema(s, elem)
+				s = elem
+			default:
+				applyValidateRule(s, name, param)
+			}
+		}
+	}
+
+	return
+}
+
+// cloneSchema 返回会被校验规则修改的schema的浅拷贝, 其他schema不会被修改, 原样返回
+func cloneSchema(s Schema) Schema {
+	switch s := s.(type) {
+	case *IdentSchema:
+		c := *s
+		return &c
+	case *ArraySchema:
+		c := *s
+		return &c
+	case *MapSchema:
+		c := *s
+		return &c
+	}
+	return s
+}
+
+// elemSchema 返回数组或map的成员schema, 用于dive规则
+func elemSchema(s Schema) Schema {
+	switch s := s.(type) {
+	case *ArraySchema:
+		return s.Items
+	case *MapSchema:
+		return s.AdditionalProperties
+	}
+	return nil
+}
+
+// setElemSchema 设置数组或map的成员schema
+func setElemSchema(s Schema, elem Schema) {
+	switch s := s.(type) {
+	case *ArraySchema:
+		s.Items = elem
+	case *MapSchema:
+		s.AdditionalProperties = elem
+	}
+}
+
+func applyValidateRule(s Schema, name, param string) {
+	if format, ok := validateFormats[name]; ok {
+		if idt, ok := s.(*IdentSchema); ok && idt.Type == "string" {
+			idt.Format = format
+		}
+		return
+	}
+
+	switch s := s.(type) {
+	case *IdentSchema:
+		switch s.Type {
+		case "integer", "number":
+			if name == "oneof" {
+				// 所有的值都是数字时才使用
+				var enum []interface{}
+				for _, v := range strings.Fields(param) {
+					f, err := strconv.ParseFloat(v, 64)
+					if err != nil {
+						return
+					}
+					enum = append(enum, f)
+				}
+				s.Enum, s.EnumVarNames, s.EnumDescriptions = enum, nil, nil
+				return
+			}
+
+			f, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return
+			}
+			switch name {
+			case "min", "gte":
+				s.Minimum = &f
+			case "max", "lte":
+				s.Maximum = &f
+			case "gt":
+				s.Minimum = &f
+				s.ExclusiveMinimum = true
+			case "lt":
+				s.Maximum = &f
+				s.ExclusiveMaximum = true
+			case "len", "eq":
+				s.Minimum = &f
+				s.Maximum = &f
+			}
+		case "string":
+			if name == "oneof" {
+				var enum []interface{}
+				for _, v := range splitOneOf(param) {
+					enum = append(enum, v)
+				}
+				s.Enum, s.EnumVarNames, s.EnumDescriptions = enum, nil, nil
+				return
+			}
+			if name == "eq" {
+				// 字符串的eq比较的是值而不是长度
+				s.Enum, s.EnumVarNames, s.EnumDescriptions = []interface{}{param}, nil, nil
+				return
+			}
+
+			min, max := lengthRule(name, param)
+			if min != nil {
+				s.MinLength = min
+			}
+			if max != nil {
+				s.MaxLength = max
+			}
+		}
+	case *ArraySchema:
+		min, max := lengthRule(name, param)
+		if min != nil {
+			s.MinItems = min
+		}
+		if max != nil {
+			s.MaxItems = max
+		}
+	case *MapSchema:
+		min, max := lengthRule(name, param)
+		if min != nil {
+			s.MinProperties = min
+		}
+		if max != nil {
+			s.MaxProperties = max
+		}
+	}
+}
+
+// lengthRule 处理作用于长度的规则, 如字符串, 数组, map
+func lengthRule(name, param string) (min, max *int) {
+	l, err := strconv.Atoi(param)
+	if err != nil {
+		return
+	}
+	switch name {
+	case "min", "gte":
+		min = &l
+	case "max", "lte":
+		max = &l
+	case "gt":
+		l++
+		min = &l
+	case "lt":
+		l--
+		max = &l
+	case "len", "eq":
+		min, max = &l, &l
+	}
+	return
+}
+
+// splitOneOf 分割oneof规则的参数, 支持单引号包裹带空格的值
+// e.g. oneof='red green' blue
+func splitOneOf(param string) []string {
+	var rs []string
+	for param != "" {
+		param = strings.TrimLeft(param, " ")
+		if param == "" {
+			break
+		}
+		if param[0] == '\'' {
+			if i := strings.IndexByte(param[1:], '\''); i != -1 {
+				rs = append(rs, param[1:i+1])
+				param = param[i+2:]
+				continue
+			}
+		}
+		i := strings.IndexByte(param, ' ')
+		if i == -1 {
+			rs = append(rs, param)
+			break
+		}
+		rs = append(rs, param[:i])
+		param = param[i:]
+	}
+	return rs
+}
@@ -5,6 +5,9 @@ import (
 	"github.com/gopenapi/gopenapi/internal/model"
 )
 
+// PetModel 是 model.PetAlias 的别名, 别名链指向 model.Pet
+type PetModel = model.PetAlias
+
 type PetUseCase interface {
 	FindPetByStatus(ctx context.Context, p *model.FindPetByStatusParams) (r []model.Pet, err error)
 	GetPet(ctx context.Context, p *model.GetPetById) (r model.Pet, exist bool, err error)
@@ -1,9 +1,9 @@
 package main
 
-import "github.com/gopenapi/gopenapi/internal/cmd"
+import "github.com/gopenapi/gopenapi/pkg/gopenapi"
 
 //go:generate go run ./internal/cmd/gen/main.go ./gopenapi.conf.js ./internal/cmd/gen.go cmd defaultConfig
 
 func main() {
-	_ = cmd.Execute()
+	_ = gopenapi.Execute()
 }
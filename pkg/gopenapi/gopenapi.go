@@ -0,0 +1,43 @@
+// Package gopenapi 是 gopenapi 命令的入口, 用于构建带有自定义 x-$ 语法的 gopenapi, e.g.
+//
+//	func main() {
+//	  _ = gopenapi.Execute(gopenapi.WithDirectives(buildDirective{}))
+//	}
+package gopenapi
+
+import (
+	"github.com/gopenapi/gopenapi/internal/cmd"
+	"github.com/gopenapi/gopenapi/internal/pkg/openapi"
+)
+
+// Directive 是用go实现的 x-$ 语法, 见 WithDirectives
+type Directive = openapi.Directive
+
+// DirectiveContext 是调用 Directive 时的上下文
+type DirectiveContext = openapi.DirectiveContext
+
+// GoStruct 是 DirectiveContext.Parse 返回的go定义的注释与schema
+type GoStruct = openapi.GoStruct
+
+// Option 是 Execute 的选项
+type Option func(*options)
+
+type options struct {
+	directives []Directive
+}
+
+// WithDirectives 注册用go实现的 x-$ 语法, 同一个名字只能注册一次
+func WithDirectives(ds ...Directive) Option {
+	return func(o *options) {
+		o.directives = append(o.directives, ds...)
+	}
+}
+
+// Execute 运行 gopenapi 命令, 参数与 gopenapi 命令相同, 读取自 os.Args
+func Execute(opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return cmd.Execute(o.directives...)
+}
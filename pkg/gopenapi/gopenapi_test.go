@@ -0,0 +1,64 @@
+package gopenapi_test
+
+import (
+	"github.com/gopenapi/gopenapi/pkg/gopenapi"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type buildDirective struct{}
+
+func (buildDirective) Name() string { return "build" }
+
+func (buildDirective) Expand(ctx *gopenapi.DirectiveContext, value interface{}, route []string) (interface{}, error) {
+	g, _, err := ctx.Parse("github.com/gopenapi/gopenapi/internal/model.Pet")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"version": "1.2.3", "x-pet": g.Summary}, nil
+}
+
+func TestExecute(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.yaml")
+	output := filepath.Join(dir, "output.json")
+	err := ioutil.WriteFile(input, []byte(`
+openapi: 3.0.1
+info:
+  title: Pet Store
+  x-$build: {}
+`), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 命令在go.mod所在的目录中运行
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"gopenapi", "-c", "gopenapi.conf.js", "-i", input, "-o", output}
+
+	err = gopenapi.Execute(gopenapi.WithDirectives(buildDirective{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := strings.Join(strings.Fields(string(bs)), "")
+	want := `"info":{"title":"PetStore","version":"1.2.3","x-pet":"Petispetmodel"}`
+	if !strings.Contains(out, want) {
+		t.Errorf("output should contains: %s, got: %s", want, out)
+	}
+}